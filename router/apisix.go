@@ -0,0 +1,358 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	apisixRoute struct {
+		ID          interface{}     `json:"id"`
+		URI         string          `json:"uri"`
+		URIs        []string        `json:"uris"`
+		Host        string          `json:"host"`
+		Hosts       []string        `json:"hosts"`
+		Methods     []string        `json:"methods"`
+		Priority    int             `json:"priority"`
+		Vars        []interface{}   `json:"vars"`
+		RemoteAddr  string          `json:"remote_addr"`
+		RemoteAddrs []string        `json:"remote_addrs"`
+		Upstream    *apisixUpstream `json:"upstream"`
+		UpstreamID  interface{}     `json:"upstream_id"`
+		ServiceID   interface{}     `json:"service_id"`
+		Status      *int            `json:"status"`
+	}
+
+	apisixUpstream struct {
+		Name  string      `json:"name"`
+		Nodes interface{} `json:"nodes"`
+	}
+
+	apisixRouteList struct {
+		List []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"list"`
+	}
+)
+
+// apisixIgnoredFields are metadata fields without influence on routing.
+var apisixIgnoredFields = []string{"id", "name", "desc", "labels", "create_time", "update_time"}
+
+// apisixRoutingFields are the fields translated into rules.
+var apisixRoutingFields = []string{
+	"uri", "uris", "host", "hosts", "methods", "priority", "vars",
+	"remote_addr", "remote_addrs", "upstream", "upstream_id", "service_id", "status",
+}
+
+// ImportAPISIX builds rules from APISIX route definitions, data is a JSON route
+// object, an array of them or the response of the Admin API list endpoint.
+//
+// The uris are translated from the lua-resty-radixtree syntax, /user/:id and
+// /files/*path, wildcard hosts like *.example.com become host regexps, remote
+// addrs become an ipmatch expression in vars and the priority orders the paths.
+// A host-less route is also added to the rules of the hosts with routes of
+// lower priority, before them, so that it wins over them like in APISIX.
+// The backend is the upstream id, the upstream name, the address of a single
+// node upstream, the service id or the route id, in that order.
+//
+// Fields that cannot be represented are reported as warnings, a route whose
+// uris, methods, vars or remote addrs cannot be represented is left out as it
+// would match more requests than intended.
+func ImportAPISIX(data []byte) ([]*Rule, []ImportWarning, error) {
+	raws, err := splitAPISIXRoutes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type entry struct {
+		paths    []*Path
+		hosts    []string
+		regexps  []string
+		priority int
+	}
+
+	entries := []*entry{}
+	warnings := []ImportWarning{}
+
+	for i, raw := range raws {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, nil, fmt.Errorf("route #%d: %v", i, err)
+		}
+
+		ar := &apisixRoute{}
+		if err := json.Unmarshal(raw, ar); err != nil {
+			return nil, nil, fmt.Errorf("route #%d: %v", i, err)
+		}
+
+		id := strconv.Itoa(i)
+		if ar.ID != nil {
+			id = fmt.Sprint(ar.ID)
+		}
+
+		warn := func(field, reason string) {
+			warnings = append(warnings, ImportWarning{Route: id, Field: field, Reason: reason})
+		}
+
+		for _, name := range unknownFields(fields, apisixIgnoredFields, apisixRoutingFields) {
+			warn(name, "not supported by the router")
+		}
+
+		if ar.Status != nil && *ar.Status == 0 {
+			warn("status", "the route is disabled, it's left out")
+			continue
+		}
+
+		paths, ok := apisixPaths(ar, id, warn)
+		if !ok {
+			continue
+		}
+
+		e := &entry{paths: paths, priority: ar.Priority}
+
+		hosts := ar.Hosts
+		if ar.Host != "" {
+			hosts = append([]string{ar.Host}, hosts...)
+		}
+		for _, host := range hosts {
+			if strings.HasPrefix(host, "*.") {
+				e.regexps = append(e.regexps, "^.+"+regexp.QuoteMeta(host[1:])+"$")
+			} else {
+				e.hosts = append(e.hosts, strings.ToLower(host))
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	// higher priorities first, the paths keep their relative order otherwise
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority > entries[j].priority
+	})
+
+	// the rules in the order of the priorities of their first paths, the
+	// lowest priority of each rule tells which host-less paths precede some of
+	// its paths
+	rules := []*Rule{}
+	keyed := map[string]*Rule{}
+	lowest := map[*Rule]int{}
+	entryRules := make([][]*Rule, len(entries))
+	var anyHost *Rule
+
+	for i, e := range entries {
+		keys := make([]string, 0, len(e.hosts)+len(e.regexps))
+		for _, host := range e.hosts {
+			keys = append(keys, "host "+host)
+		}
+		for _, re := range e.regexps {
+			keys = append(keys, "regexp "+re)
+		}
+
+		for _, key := range keys {
+			rule, ok := keyed[key]
+			if !ok {
+				rule = &Rule{}
+				if strings.HasPrefix(key, "host ") {
+					rule.Host = key[len("host "):]
+				} else {
+					rule.HostRegexp = key[len("regexp "):]
+				}
+				keyed[key] = rule
+				rules = append(rules, rule)
+			}
+			lowest[rule] = e.priority
+			entryRules[i] = append(entryRules[i], rule)
+		}
+	}
+
+	for i, e := range entries {
+		for _, rule := range entryRules[i] {
+			rule.Paths = append(rule.Paths, e.paths...)
+		}
+		if len(entryRules[i]) > 0 {
+			continue
+		}
+
+		if anyHost == nil {
+			anyHost = &Rule{}
+		}
+		anyHost.Paths = append(anyHost.Paths, e.paths...)
+
+		// APISIX tries the host-less route before the routes of lower priority
+		// of every host, not after all of them
+		for _, rule := range rules {
+			if lowest[rule] < e.priority {
+				rule.Paths = append(rule.Paths, e.paths...)
+			}
+		}
+	}
+
+	if anyHost != nil {
+		rules = append(rules, anyHost)
+	}
+
+	return rules, warnings, nil
+}
+
+func splitAPISIXRoutes(data []byte) ([]json.RawMessage, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+		return raws, nil
+	}
+
+	var list apisixRouteList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	if list.List != nil {
+		raws := make([]json.RawMessage, 0, len(list.List))
+		for _, item := range list.List {
+			raws = append(raws, item.Value)
+		}
+		return raws, nil
+	}
+
+	return []json.RawMessage{data}, nil
+}
+
+// apisixPaths returns a path for each uri of the route.
+func apisixPaths(ar *apisixRoute, id string, warn func(field, reason string)) ([]*Path, bool) {
+	uris := ar.URIs
+	if ar.URI != "" {
+		uris = append([]string{ar.URI}, uris...)
+	}
+	if len(uris) == 0 {
+		warn("uri", "missing uri, the route is left out")
+		return nil, false
+	}
+
+	path := &Path{Backend: apisixBackend(ar, id, warn)}
+
+	for _, m := range ar.Methods {
+		if _, ok := methodMap[m]; !ok {
+			warn("methods", fmt.Sprintf("method %s is not supported, the route is left out", m))
+			return nil, false
+		}
+	}
+	path.Methods = ar.Methods
+
+	for _, v := range ar.Vars {
+		if _, err := compileVars([]interface{}{v}); err != nil {
+			warn("vars", err.Error()+", the route is left out")
+			return nil, false
+		}
+		path.Vars = append(path.Vars, v)
+	}
+
+	addrs := ar.RemoteAddrs
+	if ar.RemoteAddr != "" {
+		addrs = append([]string{ar.RemoteAddr}, addrs...)
+	}
+	if len(addrs) > 0 {
+		cond := []interface{}{"remote_addr", "ipmatch", stringsToInterfaces(addrs)}
+		if _, err := compileExpr(cond); err != nil {
+			warn("remote_addrs", err.Error()+", the route is left out")
+			return nil, false
+		}
+		path.Vars = append(path.Vars, cond)
+	}
+
+	paths := make([]*Path, 0, len(uris))
+	for _, uri := range uris {
+		pattern, err := apisixURI(uri)
+		if err != nil {
+			warn("uris", err.Error()+", the route is left out")
+			return nil, false
+		}
+		p := *path
+		p.Path = pattern
+		paths = append(paths, &p)
+	}
+
+	return paths, true
+}
+
+// apisixURI translates a lua-resty-radixtree uri into the router syntax.
+func apisixURI(uri string) (string, error) {
+	if !strings.HasPrefix(uri, "/") {
+		return "", fmt.Errorf("uri %s must start with /", uri)
+	}
+	if strings.ContainsAny(uri, "{}[]") {
+		return "", fmt.Errorf("uri %s contains unsupported characters", uri)
+	}
+
+	segments := strings.Split(uri, "/")
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, ":") && len(seg) > 1:
+			segments[i] = "{" + seg[1:] + "}"
+		case strings.HasPrefix(seg, "*") && len(seg) > 1:
+			if i != len(segments)-1 {
+				return "", fmt.Errorf("uri %s has a wildcard before the end", uri)
+			}
+			segments[i] = "{" + seg[1:] + "*}"
+		case strings.IndexByte(seg, '*') >= 0 && (i != len(segments)-1 || seg[len(seg)-1] != '*'):
+			return "", fmt.Errorf("uri %s has a wildcard before the end", uri)
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+func apisixBackend(ar *apisixRoute, id string, warn func(field, reason string)) string {
+	if ar.UpstreamID != nil {
+		return fmt.Sprint(ar.UpstreamID)
+	}
+
+	if ar.Upstream != nil {
+		if ar.Upstream.Name != "" {
+			return ar.Upstream.Name
+		}
+
+		nodes := apisixNodes(ar.Upstream.Nodes)
+		if len(nodes) == 1 {
+			return nodes[0]
+		}
+		if len(nodes) > 1 {
+			warn("upstream", "load balancing over several nodes is not supported, the route id is used as backend")
+		}
+	}
+
+	if ar.ServiceID != nil {
+		return fmt.Sprint(ar.ServiceID)
+	}
+
+	return id
+}
+
+// apisixNodes returns the addresses of upstream nodes given as a map of
+// address to weight or as a list of {host, port, weight}.
+func apisixNodes(nodes interface{}) []string {
+	addrs := []string{}
+	switch n := nodes.(type) {
+	case map[string]interface{}:
+		for addr := range n {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+	case []interface{}:
+		for _, item := range n {
+			if m, ok := item.(map[string]interface{}); ok {
+				addr := fmt.Sprint(m["host"])
+				if port, ok := m["port"]; ok {
+					addr += ":" + fmt.Sprint(port)
+				}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
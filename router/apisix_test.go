@@ -0,0 +1,175 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportAPISIX(t *testing.T) {
+	data := []byte(`{
+		"total": 5,
+		"list": [
+			{"value": {
+				"id": "users",
+				"uris": ["/users/:id", "/members/:id"],
+				"hosts": ["api.example.com", "*.example.org"],
+				"methods": ["GET"],
+				"upstream": {"type": "roundrobin", "nodes": {"10.0.0.1:80": 1}}
+			}},
+			{"value": {
+				"id": "users-v2",
+				"uri": "/users/:id",
+				"host": "api.example.com",
+				"priority": 10,
+				"vars": [["http_x_version", "==", "2"]],
+				"upstream_id": "v2"
+			}},
+			{"value": {
+				"id": "internal",
+				"uri": "/internal/*rest",
+				"remote_addrs": ["10.0.0.0/8", "127.0.0.1"],
+				"plugins": {"limit-count": {}},
+				"upstream": {"nodes": [{"host": "10.0.0.2", "port": 8080, "weight": 1}, {"host": "10.0.0.3", "port": 8080, "weight": 1}]}
+			}},
+			{"value": {
+				"id": "disabled",
+				"uri": "/disabled",
+				"status": 0,
+				"service_id": "s1"
+			}},
+			{"value": {
+				"id": "purge",
+				"uri": "/cache",
+				"methods": ["PURGE"],
+				"service_id": "s1"
+			}}
+		]
+	}`)
+
+	rules, warnings, err := ImportAPISIX(data)
+	assert.Nil(t, err)
+	assert.Len(t, rules, 3)
+	assert.Equal(t, "api.example.com", rules[0].Host)
+	assert.Equal(t, `^.+\.example\.org$`, rules[1].HostRegexp)
+	assert.Equal(t, []ImportWarning{
+		{Route: "internal", Field: "plugins", Reason: "not supported by the router"},
+		{Route: "internal", Field: "upstream", Reason: "load balancing over several nodes is not supported, the route id is used as backend"},
+		{Route: "disabled", Field: "status", Reason: "the route is disabled, it's left out"},
+		{Route: "purge", Field: "methods", Reason: "method PURGE is not supported, the route is left out"},
+	}, warnings)
+
+	tests := []struct {
+		m          string
+		r          string
+		h          string
+		headers    map[string]string
+		remoteAddr string
+	}{
+		{m: "GET", r: "http://api.example.com/users/1", h: "10.0.0.1:80"},
+		{m: "GET", r: "http://api.example.com/users/1", h: "v2", headers: map[string]string{"X-Version": "2"}},
+		{m: "GET", r: "http://api.example.com/members/1", h: "10.0.0.1:80"},
+		{m: "GET", r: "http://www.example.org/members/1", h: "10.0.0.1:80"},
+		{m: "POST", r: "http://www.example.org/members/1", h: ""},
+		{m: "GET", r: "http://other.com/internal/a/b", h: "internal", remoteAddr: "10.1.2.3:1234"},
+		{m: "GET", r: "http://other.com/internal/a/b", h: "internal", remoteAddr: "127.0.0.1:1234"},
+		{m: "GET", r: "http://other.com/internal/a/b", h: "", remoteAddr: "192.168.0.1:1234"},
+		{m: "GET", r: "http://other.com/disabled", h: ""},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		req.RemoteAddr = tt.remoteAddr
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s %v", tt.m, tt.r, tt.headers)
+	}
+}
+
+func TestImportAPISIXPriority(t *testing.T) {
+	data := []byte(`[
+		{"id": "host", "uri": "/users/:id", "host": "a.com", "upstream_id": "host"},
+		{"id": "low", "uri": "/users/:id", "host": "b.com", "priority": -1, "upstream_id": "low"},
+		{"id": "any", "uri": "/users/:id", "priority": 5, "upstream_id": "any", "vars": [["arg_age", ">", "18"]]},
+		{"id": "canary", "uri": "/users/:id", "priority": 10, "upstream_id": "canary",
+			"vars": [["http_x_canary", "==", "1"], ["arg_x", ">", "abc"]]}
+	]`)
+
+	rules, warnings, err := ImportAPISIX(data)
+	assert.Nil(t, err)
+	assert.Len(t, rules, 3)
+	assert.Equal(t, []ImportWarning{
+		{Route: "canary", Field: "vars", Reason: "arg_x >: invalid value abc, the route is left out"},
+	}, warnings)
+
+	for _, rule := range rules {
+		for _, p := range rule.Paths {
+			assert.NotEqual(t, "canary", p.Backend)
+		}
+	}
+
+	tests := []struct {
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{r: "http://a.com/users/1?age=20", h: "any"},
+		{r: "http://a.com/users/1?age=10", h: "host"},
+		{r: "http://b.com/users/1?age=20", h: "any"},
+		{r: "http://b.com/users/1", h: "low"},
+		{r: "http://c.com/users/1?age=20", h: "any"},
+		{r: "http://c.com/users/1", h: ""},
+		{r: "http://c.com/users/1?x=1", h: "", headers: map[string]string{"X-Canary": "1"}},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.r, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, tt.r)
+	}
+}
+
+func TestAPISIXURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		pattern string
+		err     bool
+	}{
+		{uri: "/users/:id", pattern: "/users/{id}"},
+		{uri: "/files/*path", pattern: "/files/{path*}"},
+		{uri: "/static*", pattern: "/static*"},
+		{uri: "/a/*/b", err: true},
+		{uri: "/a/{id}", err: true},
+		{uri: "a", err: true},
+	}
+
+	for _, tt := range tests {
+		pattern, err := apisixURI(tt.uri)
+		assert.Equal(t, tt.err, err != nil, tt.uri)
+		assert.Equal(t, tt.pattern, pattern, tt.uri)
+	}
+}
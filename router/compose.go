@@ -0,0 +1,393 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The conflict modes of a composed config, see Config.
+const (
+	// ConflictError rejects the config
+	ConflictError = "error"
+	// ConflictLastWins gives the methods in conflict to the last path
+	ConflictLastWins = "last-wins"
+	// ConflictPriority gives the methods in conflict to the path with the
+	// highest priority, paths of the same priority are rejected
+	ConflictPriority = "priority"
+)
+
+type (
+	// configComposer composes the rules of config files and the files they
+	// include.
+	configComposer struct {
+		rules     []*Rule
+		conflicts string
+		// origins is where each path is declared, for the conflicts
+		origins   map[*Path]pathOrigin
+		fragments int
+		// files lists every file read, in order, and sums their content hashes
+		files   []string
+		sums    map[string][sha256.Size]byte
+		loaded  map[string]bool
+		loading []string // the stack of includes, to detect cycles
+		hash    hash.Hash
+	}
+
+	// pathOrigin is the position of a path in its fragment, a file or a config
+	// without file. The paths of a fragment never conflict, they keep their
+	// order like in a single config.
+	pathOrigin struct {
+		fragment int
+		file     string
+		line     int
+		column   int
+	}
+)
+
+func newConfigComposer() *configComposer {
+	return &configComposer{
+		origins: map[*Path]pathOrigin{},
+		sums:    map[string][sha256.Size]byte{},
+		loaded:  map[string]bool{},
+		hash:    sha256.New(),
+	}
+}
+
+// addFile reads a config file and adds it.
+func (c *configComposer) addFile(filename string, root bool) error {
+	format, err := formatOf(filename)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return c.add(data, format, filename, root)
+}
+
+// add adds a config, its includes first and then its rules. A file already
+// added, e.g. included twice, is skipped. Only a root config may set the
+// conflict mode.
+func (c *configComposer) add(data []byte, format, file string, root bool) error {
+	key := file
+	if file != "" {
+		if abs, err := filepath.Abs(file); err == nil {
+			key = abs
+		}
+		if StrInSlice(key, c.loading) {
+			return fmt.Errorf("%s: include cycle", file)
+		}
+		if c.loaded[key] {
+			return nil
+		}
+		c.loaded[key] = true
+		c.files = append(c.files, file)
+		c.sums[file] = sha256.Sum256(data)
+	}
+	fmt.Fprintf(c.hash, "%s\x00%d\x00", file, len(data))
+	c.hash.Write(data)
+
+	node, err := parseConfig(data, format, file)
+	if err != nil {
+		return err
+	}
+	if err := expandConfig(node, file); err != nil {
+		return err
+	}
+	config, err := decodeConfig(node, file)
+	if err != nil {
+		return err
+	}
+	c.fragments++
+	fragment := c.fragments
+
+	if config.Conflicts != "" {
+		if !root {
+			return fmt.Errorf("%s: conflicts can only be set in the root config", originName(file))
+		}
+		if c.conflicts != "" && c.conflicts != config.Conflicts {
+			return fmt.Errorf("%s: conflicts '%s' differs from '%s' of another root config", originName(file), config.Conflicts, c.conflicts)
+		}
+		c.conflicts = config.Conflicts
+	}
+
+	c.loading = append(c.loading, key)
+	defer func() { c.loading = c.loading[:len(c.loading)-1] }()
+
+	for _, pattern := range config.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+		files := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			// the matches are sorted, a pattern without matches is fine
+			if files, err = filepath.Glob(pattern); err != nil {
+				return fmt.Errorf("%s: include '%s': %v", originName(file), pattern, err)
+			}
+		}
+		for _, f := range files {
+			if err := c.addFile(f, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	nodes := pathNodes(node, config.Rules)
+	for _, rule := range config.Rules {
+		for _, p := range rule.Paths {
+			origin := pathOrigin{fragment: fragment, file: file}
+			if n, ok := nodes[p]; ok {
+				origin.line, origin.column = n.Line, n.Column
+			}
+			c.origins[p] = origin
+		}
+	}
+	c.rules = append(c.rules, config.Rules...)
+	return nil
+}
+
+// compose merges the rules of the same host and resolves the conflicts of
+// their paths with the conflict mode, the one of the root config if empty.
+func (c *configComposer) compose(mode string) ([]*Rule, error) {
+	if mode == "" {
+		mode = c.conflicts
+	}
+	return resolveConflicts(mergeRules(c.rules), mode, c.origins)
+}
+
+// version returns the hash of the names and contents of the configs added.
+func (c *configComposer) version() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
+
+func originName(file string) string {
+	if file == "" {
+		return "<config>"
+	}
+	return file
+}
+
+// mergeRules merges the rules of the same host, the paths keep their order and
+// the rules the order of the first one of each host.
+func mergeRules(rules []*Rule) []*Rule {
+	merged := []*Rule{}
+	byHost := map[[2]string]*Rule{}
+	for _, r := range rules {
+		key := [2]string{r.Host, r.HostRegexp}
+		m, ok := byHost[key]
+		if !ok {
+			m = &Rule{Host: r.Host, HostRegexp: r.HostRegexp}
+			byHost[key] = m
+			merged = append(merged, m)
+		}
+		m.Paths = append(m.Paths, r.Paths...)
+	}
+	return merged
+}
+
+// resolveConflicts resolves the paths of each rule from different fragments
+// declaring the same method, pattern and predicates, the paths are copied when
+// they lose methods and dropped when they lose them all. The conflicts left
+// are returned as ConfigErrors at the later path.
+func resolveConflicts(rules []*Rule, mode string, origins map[*Path]pathOrigin) ([]*Rule, error) {
+	switch mode {
+	case "", ConflictError, ConflictLastWins, ConflictPriority:
+	default:
+		return nil, fmt.Errorf("unknown conflict mode '%s'", mode)
+	}
+
+	resolved := make([]*Rule, 0, len(rules))
+	errs := ConfigErrors{}
+	for _, rule := range rules {
+		paths, ruleErrs := resolvePaths(rule, mode, origins)
+		errs = append(errs, ruleErrs...)
+		resolved = append(resolved, &Rule{Host: rule.Host, HostRegexp: rule.HostRegexp, Paths: paths})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return resolved, nil
+}
+
+func resolvePaths(rule *Rule, mode string, origins map[*Path]pathOrigin) ([]*Path, ConfigErrors) {
+	methods := make([][]string, len(rule.Paths))
+	lost := make([]bool, len(rule.Paths))
+	// conflict key and method to the indexes of the paths owning them, all of
+	// the same fragment
+	owners := map[string][]int{}
+	errs := ConfigErrors{}
+
+	for i, p := range rule.Paths {
+		methods[i] = p.Methods
+		if len(methods[i]) == 0 {
+			methods[i] = allMethods()
+		}
+
+		key := conflictKey(p)
+		conflicting := []int{}
+		for _, m := range append([]string(nil), methods[i]...) {
+			group := owners[key+m]
+			if len(group) == 0 || origins[rule.Paths[group[0]]].fragment == origins[p].fragment {
+				owners[key+m] = append(group, i)
+				continue
+			}
+
+			priority := rule.Paths[group[0]].Priority
+			for _, j := range group[1:] {
+				if rule.Paths[j].Priority > priority {
+					priority = rule.Paths[j].Priority
+				}
+			}
+
+			wins := true
+			switch {
+			case mode == ConflictLastWins:
+			case mode == ConflictPriority && p.Priority != priority:
+				wins = p.Priority > priority
+			default:
+				if !containsInt(conflicting, group[0]) {
+					conflicting = append(conflicting, group[0])
+				}
+				continue
+			}
+
+			if wins {
+				for _, j := range group {
+					methods[j] = removeString(methods[j], m)
+					lost[j] = true
+				}
+				owners[key+m] = []int{i}
+			} else {
+				methods[i] = removeString(methods[i], m)
+				lost[i] = true
+			}
+		}
+
+		for _, j := range conflicting {
+			other := rule.Paths[j]
+			o := origins[p]
+			errs = append(errs, &ConfigError{File: o.file, Line: o.line, Column: o.column, Msg: fmt.Sprintf(
+				"path %s conflicts on %s for %s with the path at %s", p.Path, describeHost(rule), conflictMethods(other, p), origins[other])})
+		}
+	}
+
+	paths := make([]*Path, 0, len(rule.Paths))
+	for i, p := range rule.Paths {
+		if lost[i] {
+			if len(methods[i]) == 0 {
+				continue
+			}
+			cp := *p
+			cp.Methods = methods[i]
+			p = &cp
+		}
+		paths = append(paths, p)
+	}
+	return paths, errs
+}
+
+// conflictMethods describes the methods both paths declare.
+func conflictMethods(a, b *Path) string {
+	switch {
+	case len(a.Methods) == 0 && len(b.Methods) == 0:
+		return "any method"
+	case len(a.Methods) == 0:
+		return strings.Join(b.Methods, ", ")
+	case len(b.Methods) == 0:
+		return strings.Join(a.Methods, ", ")
+	}
+	both := []string{}
+	for _, m := range a.Methods {
+		if StrInSlice(m, b.Methods) {
+			both = append(both, m)
+		}
+	}
+	return strings.Join(both, ", ")
+}
+
+func (o pathOrigin) String() string {
+	pos := &ConfigError{File: o.file, Line: o.line, Column: o.column}
+	if s := pos.Error(); s != "" {
+		return strings.TrimSuffix(s, ": ")
+	}
+	return "<config>"
+}
+
+// conflictKey identifies the pattern and predicates of a path, empty lists and
+// the default path type are normalized.
+func conflictKey(p *Path) string {
+	cp := *p
+	cp.Backend, cp.Methods, cp.Priority = "", nil, 0
+	if cp.PathType == PathTypeImplementationSpecific {
+		cp.PathType = ""
+	}
+	if len(cp.Headers) == 0 {
+		cp.Headers = nil
+	}
+	if len(cp.Queries) == 0 {
+		cp.Queries = nil
+	}
+	if len(cp.Produces) == 0 {
+		cp.Produces = nil
+	}
+	if len(cp.Consumes) == 0 {
+		cp.Consumes = nil
+	}
+	if len(cp.Languages) == 0 {
+		cp.Languages = nil
+	}
+	if len(cp.Vars) == 0 {
+		cp.Vars = nil
+	}
+	if len(cp.Matchers) == 0 {
+		cp.Matchers = nil
+	}
+	data, _ := json.Marshal(cp)
+	return string(data) + "\x00"
+}
+
+func describeHost(rule *Rule) string {
+	switch {
+	case rule.Host != "":
+		return "host " + rule.Host
+	case rule.HostRegexp != "":
+		return "host ~ " + rule.HostRegexp
+	}
+	return "any host"
+}
+
+func allMethods() []string {
+	methods := make([]string, 0, len(methodMap))
+	for m := range methodMap {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func containsInt(slice []int, n int) bool {
+	for _, v := range slice {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(slice []string, s string) []string {
+	res := make([]string, 0, len(slice))
+	for _, v := range slice {
+		if v != s {
+			res = append(res, v)
+		}
+	}
+	return res
+}
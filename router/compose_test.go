@@ -0,0 +1,208 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFileIncludes(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "teams"), 0o755))
+	files := map[string]string{
+		"root.yaml": `
+include: [teams/*.yaml, common.json]
+conflicts: last-wins
+rules:
+- host: api.example.com
+  paths:
+  - {path: /users, backend: users-v2, methods: [POST]}
+`,
+		"teams/a.yaml": "- host: api.example.com\n  paths:\n  - {path: /orders, backend: orders}\n",
+		"teams/b.yaml": "include: [../common.json]\nrules:\n- host: api.example.com\n  paths:\n  - {path: /users, backend: users, methods: [GET, POST]}\n",
+		"common.json":  `[{"paths": [{"path": "/health", "backend": "health"}]}]`,
+	}
+	for name, data := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	rules, err := LoadFile(filepath.Join(dir, "root.yaml"))
+	assert.Nil(t, err)
+	// common.json is included once, the fragments of the host are merged and
+	// the root takes POST /users
+	assert.Equal(t, []*Rule{
+		{
+			Host: "api.example.com",
+			Paths: []*Path{
+				{Path: "/orders", Backend: "orders"},
+				{Path: "/users", Backend: "users", Methods: []string{"GET"}},
+				{Path: "/users", Backend: "users-v2", Methods: []string{"POST"}},
+			},
+		},
+		{Paths: []*Path{{Path: "/health", Backend: "health"}}},
+	}, rules)
+}
+
+func TestLoadFileIncludeErrors(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		err   string
+	}{
+		{
+			files: map[string]string{"root.yaml": "include: [a.yaml]\n", "a.yaml": "include: [root.yaml]\n"},
+			err:   "root.yaml: include cycle",
+		},
+		{
+			files: map[string]string{"root.yaml": "include: [a.yaml]\n", "a.yaml": "conflicts: last-wins\n"},
+			err:   "a.yaml: conflicts can only be set in the root config",
+		},
+		{
+			files: map[string]string{"root.yaml": "include: [missing.yaml]\n"},
+			err:   "missing.yaml: no such file or directory",
+		},
+		{
+			files: map[string]string{"root.yaml": "include: [a.yaml]\nrules: [{paths: [{path: /a, backend: a2}]}]\n", "a.yaml": "- paths: [{path: /a, backend: a1}]\n"},
+			err:   "DIR/root.yaml:2:18: path /a conflicts on any host for any method with the path at DIR/a.yaml:1:11",
+		},
+		{
+			files: map[string]string{"root.yaml": "include: [a.yaml]\nrules: []\n", "a.yaml": "- paths: [{path: a, backend: a}]\n"},
+			err:   "a.yaml:1:18: path 'a' must match ^/",
+		},
+		{
+			files: map[string]string{"root.yaml": "include: a.yaml\nconflicts: first-wins\n"},
+			err:   "1:10: expected a list\nDIR/root.yaml:2:12: conflicts 'first-wins' must be one of error, last-wins, priority",
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, data := range tt.files {
+			assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+		}
+		_, err := LoadFile(filepath.Join(dir, "root.yaml"))
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), strings.ReplaceAll(tt.err, "DIR", dir))
+		}
+	}
+}
+
+func TestLoadRepeatedPatterns(t *testing.T) {
+	// the paths of a single config keep their order, the first one wins
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{format: FormatYAML, data: "- paths:\n  - {path: '/article/{id}', backend: a1}\n  - {path: '/article/{id}', backend: a2}\n"},
+		{format: FormatJSON, data: `[{"paths": [{"path": "/x", "backend": "x1"}, {"path": "/x", "backend": "x2", "methods": ["GET"]}]}]`},
+	}
+
+	for _, tt := range tests {
+		rules, err := Load(strings.NewReader(tt.data), tt.format)
+		if assert.Nil(t, err, tt.format) {
+			assert.Len(t, rules[0].Paths, 2, tt.format)
+		}
+	}
+
+	// the conflicts are between files
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("- paths:\n  - {path: /x, backend: a, methods: [GET, PUT]}\n"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "root.yaml"), []byte("include: [a.yaml]\nrules:\n- paths:\n  - {path: /x, backend: b, methods: [POST, PUT, GET]}\n"), 0o644))
+	_, err := LoadFile(filepath.Join(dir, "root.yaml"))
+	assert.Equal(t, ConfigErrors{{
+		File:   filepath.Join(dir, "root.yaml"),
+		Line:   4,
+		Column: 5,
+		Msg:    fmt.Sprintf("path /x conflicts on any host for GET, PUT with the path at %s:2:5", filepath.Join(dir, "a.yaml")),
+	}}, err)
+}
+
+func TestResolveConflicts(t *testing.T) {
+	a := &Path{Path: "/a", Backend: "a", Methods: []string{"GET", "POST"}, Priority: 1}
+	b := &Path{Path: "/a", Backend: "b", Methods: []string{"GET"}, Headers: []*Header{}}
+	c := &Path{Path: "/a", Backend: "c", Methods: []string{"GET"}, Headers: []*Header{{Key: "X"}}}
+	d := &Path{Path: "/a", Backend: "d", Methods: []string{"POST"}, Priority: 1}
+	e := &Path{Path: "/a", Backend: "e"}
+	origins := map[*Path]pathOrigin{
+		a: {fragment: 1, file: "a.yaml", line: 1, column: 3},
+		b: {fragment: 2, file: "b.yaml", line: 2, column: 3},
+		c: {fragment: 3, file: "c.yaml", line: 3, column: 3},
+		d: {fragment: 4, file: "d.yaml", line: 4, column: 3},
+		e: {fragment: 1, file: "a.yaml", line: 5, column: 3},
+	}
+
+	tests := []struct {
+		mode  string
+		paths []*Path
+		want  []*Path
+		err   string
+	}{
+		{
+			mode:  ConflictError,
+			paths: []*Path{a, c},
+			want:  []*Path{a, c},
+		},
+		{
+			mode:  "",
+			paths: []*Path{a, b},
+			err:   "b.yaml:2:3: path /a conflicts on host a.com for GET with the path at a.yaml:1:3",
+		},
+		{
+			mode:  ConflictLastWins,
+			paths: []*Path{a, b, c},
+			want:  []*Path{{Path: "/a", Backend: "a", Methods: []string{"POST"}, Priority: 1}, b, c},
+		},
+		{
+			mode:  ConflictLastWins,
+			paths: []*Path{b, a},
+			want:  []*Path{a},
+		},
+		{
+			mode:  ConflictPriority,
+			paths: []*Path{a, b},
+			want:  []*Path{a},
+		},
+		{
+			mode:  ConflictPriority,
+			paths: []*Path{a, d},
+			err:   "d.yaml:4:3: path /a conflicts on host a.com for POST with the path at a.yaml:1:3",
+		},
+		{
+			// the paths of a fragment keep their order
+			mode:  ConflictError,
+			paths: []*Path{a, e},
+			want:  []*Path{a, e},
+		},
+		{
+			// the later fragment takes the methods of every path of the earlier one
+			mode:  ConflictLastWins,
+			paths: []*Path{a, e, d},
+			want:  []*Path{{Path: "/a", Backend: "a", Methods: []string{"GET"}, Priority: 1}, {Path: "/a", Backend: "e", Methods: removeString(allMethods(), "POST")}, d},
+		},
+		{
+			mode:  ConflictError,
+			paths: []*Path{e, b},
+			err:   "b.yaml:2:3: path /a conflicts on host a.com for GET with the path at a.yaml:5:3",
+		},
+		{
+			mode:  "first-wins",
+			paths: []*Path{a},
+			err:   "unknown conflict mode 'first-wins'",
+		},
+	}
+
+	for _, tt := range tests {
+		rules, err := resolveConflicts([]*Rule{{Host: "a.com", Paths: tt.paths}}, tt.mode, origins)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.mode)
+			continue
+		}
+		assert.Nil(t, err, tt.mode)
+		assert.Equal(t, []*Rule{{Host: "a.com", Paths: tt.want}}, rules, tt.mode)
+	}
+	// the paths are not modified
+	assert.Equal(t, []string{"GET", "POST"}, a.Methods)
+}
@@ -0,0 +1,492 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The formats of a config, a config is a list of rules or a Config.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+type (
+	// ConfigError is an error at a position of a config file, Line and Column
+	// start at 1 and are 0 if unknown.
+	ConfigError struct {
+		File   string
+		Line   int
+		Column int
+		Msg    string
+	}
+
+	// ConfigErrors lists all the errors found in a config.
+	ConfigErrors []*ConfigError
+
+	configValidator struct {
+		file string
+		errs ConfigErrors
+	}
+)
+
+func (e *ConfigError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		if pos != "" {
+			pos += ":"
+		}
+		pos += strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column)
+	}
+	if pos == "" {
+		return e.Msg
+	}
+	return pos + ": " + e.Msg
+}
+
+func (errs ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// LoadFile loads the rules from a JSON or YAML file, the format is chosen by
+// the extension, .json, .yaml or .yml.
+func LoadFile(filename string) ([]*Rule, error) {
+	c := newConfigComposer()
+	if err := c.addFile(filename, true); err != nil {
+		return nil, err
+	}
+	return c.compose("")
+}
+
+// Load loads the rules from r in the format, FormatJSON or FormatYAML.
+//
+// The config is validated against the constraints of the jsonschema tags of
+// Rule and the types below it, unknown fields are rejected and the paths must
+// be accepted by New. The errors are returned as ConfigErrors with the line and
+// column of each one.
+//
+// A Config may include other files, the rules of the same host are merged and
+// the paths of a host from different files declaring the same method, pattern
+// and predicates are handled by its conflict mode, the paths of a file keep
+// their order. Without a file the includes are relative to the working
+// directory.
+//
+// The values of the config may refer to environment variables, ${VAR} or
+// ${VAR:-default} which defaults an unset or empty VAR, and pipe them through
+// the helpers lower, upper, trim, quoteMeta, trimPrefix s, trimSuffix s and
+// replace old new, e.g. ${HOST | quoteMeta}. The braces of a default or an
+// argument are balanced or escaped as \{ and \}, a pipe is escaped as \|.
+// $${ is a literal ${. A plain YAML
+// value gets the type of the substituted value, a value with ${ must be quoted
+// in a YAML flow collection. Every unresolved variable is reported.
+func Load(r io.Reader, format string) ([]*Rule, error) {
+	return load(r, format, "")
+}
+
+func formatOf(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("%s: unknown config format", filename)
+}
+
+func load(r io.Reader, format, file string) ([]*Rule, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newConfigComposer()
+	if err := c.add(data, format, file, true); err != nil {
+		return nil, err
+	}
+	return c.compose("")
+}
+
+// parseConfig parses the config into a YAML node, which keeps the positions.
+// JSON is tokenized into the node, YAML doesn't take all its escapes, e.g. \/.
+func parseConfig(data []byte, format, file string) (*yaml.Node, error) {
+	switch format {
+	case FormatJSON:
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			if se, ok := err.(*json.SyntaxError); ok {
+				line, col := offsetPosition(data, se.Offset)
+				return nil, ConfigErrors{{File: file, Line: line, Column: col, Msg: se.Error()}}
+			}
+			return nil, ConfigErrors{{File: file, Msg: err.Error()}}
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		node, err := jsonNode(dec, data)
+		if err != nil {
+			return nil, ConfigErrors{{File: file, Msg: err.Error()}}
+		}
+		return &yaml.Node{Kind: yaml.DocumentNode, Line: 1, Column: 1, Content: []*yaml.Node{node}}, nil
+	case FormatYAML:
+	default:
+		return nil, fmt.Errorf("unknown config format '%s'", format)
+	}
+
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return nil, ConfigErrors{yamlError(err, file)}
+	}
+	return node, nil
+}
+
+// jsonNode decodes the next JSON value into a node at the position of its
+// first byte.
+func jsonNode(dec *json.Decoder, data []byte) (*yaml.Node, error) {
+	start := dec.InputOffset()
+	for start < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[start]) >= 0 {
+		start++
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{Kind: yaml.ScalarNode}
+	node.Line, node.Column = offsetPosition(data, start+1)
+	switch t := tok.(type) {
+	case json.Delim:
+		node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		if t == '{' {
+			node.Kind, node.Tag = yaml.MappingNode, "!!map"
+		}
+		for dec.More() {
+			child, err := jsonNode(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		// the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		node.Tag, node.Value, node.Style = "!!str", t, yaml.DoubleQuotedStyle
+	case json.Number:
+		node.Tag, node.Value = "!!float", string(t)
+		if _, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			node.Tag = "!!int"
+		}
+	case bool:
+		node.Tag, node.Value = "!!bool", strconv.FormatBool(t)
+	default:
+		node.Tag, node.Value = "!!null", "null"
+	}
+	return node, nil
+}
+
+// decodeConfig validates the node and decodes it into a config, a list of
+// rules is decoded into its Rules.
+func decodeConfig(node *yaml.Node, file string) (*Config, error) {
+	config := &Config{Rules: []*Rule{}}
+	node = configRoot(node)
+	if node == nil {
+		return config, nil
+	}
+
+	v := &configValidator{file: file}
+	typ, rulesNode := reflect.TypeOf([]*Rule{}), node
+	if node.Kind == yaml.MappingNode {
+		typ, rulesNode = reflect.TypeOf(Config{}), mappingValue(node, "rules")
+	}
+	v.validate(node, typ)
+	if len(v.errs) > 0 {
+		return nil, v.errs
+	}
+
+	// the YAML decoder doesn't know the json tags, the config is decoded through JSON
+	var raw interface{}
+	if err := node.Decode(&raw); err != nil {
+		return nil, ConfigErrors{yamlError(err, file)}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, ConfigErrors{{File: file, Msg: err.Error()}}
+	}
+	target := interface{}(config)
+	if node.Kind == yaml.SequenceNode {
+		target = &config.Rules
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return nil, ConfigErrors{{File: file, Msg: err.Error()}}
+	}
+	if config.Rules == nil {
+		config.Rules = []*Rule{}
+	}
+
+	if rulesNode != nil {
+		v.checkPaths(rulesNode, config.Rules)
+	}
+	if len(v.errs) > 0 {
+		return nil, v.errs
+	}
+	return config, nil
+}
+
+// configRoot returns the top node of a parsed config, nil for an empty document.
+func configRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == 0 {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// pathNodes maps the paths of the rules decoded from a parsed config to their
+// nodes, for the positions of the errors found after decoding.
+func pathNodes(node *yaml.Node, rules []*Rule) map[*Path]*yaml.Node {
+	nodes := map[*Path]*yaml.Node{}
+	rulesNode := configRoot(node)
+	if rulesNode != nil && rulesNode.Kind == yaml.MappingNode {
+		rulesNode = mappingValue(rulesNode, "rules")
+	}
+	if rulesNode == nil {
+		return nodes
+	}
+	if rulesNode.Kind == yaml.AliasNode {
+		rulesNode = rulesNode.Alias
+	}
+
+	for i, rule := range rules {
+		if i >= len(rulesNode.Content) {
+			break
+		}
+		pathsNode := mappingValue(rulesNode.Content[i], "paths")
+		if pathsNode != nil && pathsNode.Kind == yaml.AliasNode {
+			pathsNode = pathsNode.Alias
+		}
+		for j, p := range rule.Paths {
+			if pathsNode != nil && j < len(pathsNode.Content) {
+				nodes[p] = pathsNode.Content[j]
+			}
+		}
+	}
+	return nodes
+}
+
+var yamlLineRE = regexp.MustCompile(`^yaml: line (\d+): `)
+
+func yamlError(err error, file string) *ConfigError {
+	msg := err.Error()
+	if m := yamlLineRE.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &ConfigError{File: file, Line: line, Column: 1, Msg: msg[len(m[0]):]}
+	}
+	return &ConfigError{File: file, Msg: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// offsetPosition returns the position of the byte before the offset, the offset
+// of a json.SyntaxError is after the offending byte.
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > 0 {
+		offset--
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+func (v *configValidator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ConfigError{File: v.file, Line: node.Line, Column: node.Column, Msg: fmt.Sprintf(format, args...)})
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// validate checks the node against the type and the jsonschema tags of its fields.
+func (v *configValidator) validate(node *yaml.Node, typ reflect.Type) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	// null is an empty list, like in the schema, other types reject it
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" && typ.Kind() == reflect.Slice {
+		return
+	}
+
+	switch {
+	case typ == rawMessageType || typ.Kind() == reflect.Interface:
+		// any value
+
+	case typ.Kind() == reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "expected an object")
+			return
+		}
+		v.validateStruct(node, typ)
+
+	case typ.Kind() == reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			v.errorf(node, "expected a list")
+			return
+		}
+		for _, item := range node.Content {
+			v.validate(item, typ.Elem())
+		}
+
+	case typ.Kind() == reflect.String:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			v.errorf(node, "expected a string")
+		}
+
+	case typ.Kind() == reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			v.errorf(node, "expected a boolean")
+		}
+
+	case typ.Kind() == reflect.Int:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			v.errorf(node, "expected an integer")
+		}
+	}
+}
+
+func (v *configValidator) validateStruct(node *yaml.Node, typ reflect.Type) {
+	fields := map[string]reflect.StructField{}
+	required := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := jsonFieldName(f)
+		if name == "" {
+			continue
+		}
+		fields[name] = f
+		if parseSchemaTag(f.Tag.Get("jsonschema")).required {
+			required = append(required, name)
+		}
+	}
+
+	seen := []string{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		f, ok := fields[key.Value]
+		if !ok {
+			v.errorf(key, "unknown field '%s'", key.Value)
+			continue
+		}
+		seen = append(seen, key.Value)
+		v.validate(value, f.Type)
+		v.checkConstraints(key.Value, value, parseSchemaTag(f.Tag.Get("jsonschema")))
+	}
+
+	for _, name := range required {
+		if !StrInSlice(name, seen) {
+			v.errorf(node, "missing required field '%s'", name)
+		}
+	}
+}
+
+// checkConstraints checks the pattern, format, uniqueItems and enum
+// constraints of a jsonschema tag.
+func (v *configValidator) checkConstraints(name string, node *yaml.Node, tag schemaTag) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+
+	if tag.pattern != "" && node.Kind == yaml.ScalarNode && !regexp.MustCompile(tag.pattern).MatchString(node.Value) {
+		v.errorf(node, "%s '%s' must match %s", name, node.Value, tag.pattern)
+	}
+
+	switch tag.format {
+	case "regexp":
+		if node.Kind != yaml.ScalarNode {
+			break
+		}
+		if _, err := regexp.Compile(node.Value); err != nil {
+			v.errorf(node, "%s: invalid regexp: %v", name, err)
+		}
+	case "httpmethod-array":
+		for _, item := range node.Content {
+			if _, ok := methodMap[item.Value]; !ok {
+				v.errorf(item, "%s: unknown method '%s'", name, item.Value)
+			}
+		}
+	}
+
+	if tag.uniqueItems && node.Kind == yaml.SequenceNode {
+		items := []string{}
+		for _, item := range node.Content {
+			if StrInSlice(item.Value, items) {
+				v.errorf(item, "%s: duplicate item '%s'", name, item.Value)
+			}
+			items = append(items, item.Value)
+		}
+	}
+
+	if len(tag.enum) > 0 && node.Kind == yaml.ScalarNode && !StrInSlice(node.Value, tag.enum) {
+		v.errorf(node, "%s '%s' must be one of %s", name, node.Value, strings.Join(tag.enum, ", "))
+	}
+}
+
+// checkPaths reports the paths New would reject at the position of the path.
+func (v *configValidator) checkPaths(node *yaml.Node, rules []*Rule) {
+	for i, rule := range rules {
+		ruleNode := node.Content[i]
+		if rule.HostRegexp != "" {
+			if _, err := regexp.Compile(rule.HostRegexp); err != nil {
+				v.errorf(ruleNode, "hostRegexp: invalid regexp: %v", err)
+			}
+		}
+
+		pathsNode := mappingValue(ruleNode, "paths")
+		for j, path := range rule.Paths {
+			if pathsNode == nil || j >= len(pathsNode.Content) {
+				continue
+			}
+			if err := checkPath(path); err != nil {
+				v.errorf(pathsNode.Content[j], "%v", err)
+			}
+		}
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
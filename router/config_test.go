@@ -0,0 +1,189 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfigYAML = `
+- host: api.example.com
+  paths:
+  - path: /users/{id:int}
+    backend: users
+    methods: [GET, HEAD]
+    headers:
+    - key: X-Version
+      values: ["2"]
+    vars:
+    - [arg_debug, "!", "==", "1"]
+- paths:
+  - path: /static
+    pathType: Prefix
+    backend: static
+    matchAllHeader: true
+`
+
+const testConfigJSON = `[
+	{
+		"host": "api.example.com",
+		"paths": [
+			{
+				"path": "/users/{id:int}",
+				"backend": "users",
+				"methods": ["GET", "HEAD"],
+				"headers": [{"key": "X-Version", "values": ["2"]}],
+				"vars": [["arg_debug", "!", "==", "1"]]
+			}
+		]
+	},
+	{
+		"paths": [{"path": "/static", "pathType": "Prefix", "backend": "static", "matchAllHeader": true}]
+	}
+]`
+
+func TestLoad(t *testing.T) {
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		data := testConfigYAML
+		if format == FormatJSON {
+			data = testConfigJSON
+		}
+
+		rules, err := Load(strings.NewReader(data), format)
+		assert.Nil(t, err, format)
+		assert.Equal(t, []*Rule{
+			{
+				Host: "api.example.com",
+				Paths: []*Path{{
+					Path:    "/users/{id:int}",
+					Backend: "users",
+					Methods: []string{"GET", "HEAD"},
+					Headers: []*Header{{Key: "X-Version", Values: []string{"2"}}},
+					Vars:    []interface{}{[]interface{}{"arg_debug", "!", "==", "1"}},
+				}},
+			},
+			{
+				Paths: []*Path{{Path: "/static", PathType: PathTypePrefix, Backend: "static", MatchAllHeader: true}},
+			},
+		}, rules, format)
+
+		router := New(rules, false)
+		req, _ := http.NewRequest("GET", "http://api.example.com/users/1", nil)
+		req.Header.Set("X-Version", "2")
+		assert.Equal(t, "users", router.Search(req).Route.backend, format)
+	}
+
+	rules, err := Load(strings.NewReader(""), FormatYAML)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Rule{}, rules)
+
+	_, err = Load(strings.NewReader("[]"), "toml")
+	assert.NotNil(t, err)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		errs   []string
+	}{
+		{
+			format: FormatYAML,
+			data: `
+- host: a.com
+  paths:
+  - path: users
+    backend: users
+    methods: [GET, FETCH, GET]
+    pathType: Fuzzy
+  - path: /a
+    headers:
+    - key: X
+      regexp: "["
+    weight: 1
+`,
+			errs: []string{
+				"4:11: path 'users' must match ^/",
+				"6:20: methods: unknown method 'FETCH'",
+				"6:27: methods: duplicate item 'GET'",
+				"7:15: pathType 'Fuzzy' must be one of Exact, Prefix, RegularExpression, ServeMux, ImplementationSpecific",
+				"11:15: regexp: invalid regexp: error parsing regexp: missing closing ]: `[`",
+				"12:5: unknown field 'weight'",
+				"8:5: missing required field 'backend'",
+			},
+		},
+		{
+			format: FormatYAML,
+			data:   "- host: 1\n  paths: {}\n- null\n",
+			errs:   []string{"1:9: expected a string", "2:10: expected a list", "3:3: expected an object"},
+		},
+		{
+			format: FormatYAML,
+			data:   "- paths:\n  - path: /a/{id\n    backend: a\n",
+			errs:   []string{"2:5: route param closing delimiter '}' is missing"},
+		},
+		{
+			format: FormatYAML,
+			data:   "- paths:\n  - path: /a\n   backend: a\n",
+			errs:   []string{"2:1: did not find expected key"},
+		},
+		{
+			format: FormatJSON,
+			data:   "[\n  {\"paths\": [}\n]",
+			errs:   []string{"2:14: invalid character '}' looking for beginning of value"},
+		},
+		{
+			format: FormatJSON,
+			data:   `[{"paths": [{"path": "/a", "backend": "a", "vars": [["arg_x", "=", 1]]}]}]`,
+			errs:   []string{"1:13: invalid vars in route '/a': arg_x: unknown operator '='"},
+		},
+		{
+			format: FormatJSON,
+			data:   "[{\"paths\": [\n  {\"path\": \"\\/a\", \"backend\": \"caf\\u00e9\", \"pathType\": \"Fuzzy\"}]}]",
+			errs:   []string{"2:55: pathType 'Fuzzy' must be one of Exact, Prefix, RegularExpression, ServeMux, ImplementationSpecific"},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Load(strings.NewReader(tt.data), tt.format)
+		errs, ok := err.(ConfigErrors)
+		if !assert.True(t, ok, "%v", err) {
+			continue
+		}
+		msgs := []string{}
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		assert.Equal(t, tt.errs, msgs)
+	}
+}
+
+func TestLoadJSONEscapes(t *testing.T) {
+	data := `{"rules": [{"host": "a.com", "paths": [{"path": "\/a\/b", "backend": "caf\u00e9 \"\ud83d\ude00\""}]}]}`
+
+	rules, err := Load(strings.NewReader(data), FormatJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, "/a/b", rules[0].Paths[0].Path)
+	assert.Equal(t, "café \"\U0001F600\"", rules[0].Paths[0].Backend)
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "rules.yml")
+	assert.Nil(t, os.WriteFile(filename, []byte("- paths:\n  - path: /a\n"), 0o644))
+
+	_, err := LoadFile(filename)
+	assert.Equal(t, filename+":2:5: missing required field 'backend'", err.Error())
+
+	assert.Nil(t, os.WriteFile(filename, []byte(testConfigYAML), 0o644))
+	rules, err := LoadFile(filename)
+	assert.Nil(t, err)
+	assert.Len(t, rules, 2)
+
+	_, err = LoadFile(filepath.Join(dir, "rules.txt"))
+	assert.NotNil(t, err)
+}
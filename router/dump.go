@@ -0,0 +1,164 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DumpTree writes the compiled tree and the path cache of each rule as
+// indented text. A node shows its type, prefix, label, tail and regexp or
+// param type, then its routes, and its children in the order of the search.
+func (ar *ArtRouter) DumpTree(w io.Writer) error {
+	var sb strings.Builder
+	for _, mr := range ar.rules {
+		fmt.Fprintf(&sb, "rule %s\n", mr.dumpName())
+		mr.root.dump(&sb, 1)
+
+		if mr.disablePathCache {
+			sb.WriteString("  path cache disabled\n")
+			continue
+		}
+		sb.WriteString("  path cache\n")
+		for _, path := range mr.pathCache.paths() {
+			fmt.Fprintf(&sb, "    %s\n", path)
+			for _, r := range mr.pathCache[path] {
+				fmt.Fprintf(&sb, "      route %s\n", r.summary())
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteDOT writes the trees and the path caches as a Graphviz digraph, with a
+// cluster per rule, e.g. for dot -Tsvg.
+func (ar *ArtRouter) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph router {\n")
+	sb.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+
+	for i, mr := range ar.rules {
+		fmt.Fprintf(&sb, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&sb, "    label=%s;\n", dotLabel("rule "+mr.dumpName()))
+
+		id := 0
+		var walk func(n *node, parent string)
+		walk = func(n *node, parent string) {
+			name := fmt.Sprintf("r%dn%d", i, id)
+			id++
+
+			lines := []string{"root"}
+			if n != mr.root {
+				lines[0] = n.describe()
+			}
+			for _, r := range n.routes {
+				lines = append(lines, "route "+r.summary())
+			}
+			fmt.Fprintf(&sb, "    %s [label=%s];\n", name, dotLabel(lines...))
+			if parent != "" {
+				fmt.Fprintf(&sb, "    %s -> %s;\n", parent, name)
+			}
+
+			for _, nds := range n.children {
+				for _, child := range nds {
+					walk(child, name)
+				}
+			}
+		}
+		walk(mr.root, "")
+
+		if !mr.disablePathCache && len(mr.pathCache) > 0 {
+			lines := []string{"path cache"}
+			for _, path := range mr.pathCache.paths() {
+				for _, r := range mr.pathCache[path] {
+					lines = append(lines, fmt.Sprintf("%s: %s", path, r.summary()))
+				}
+			}
+			fmt.Fprintf(&sb, "    r%dcache [shape=note, label=%s];\n", i, dotLabel(lines...))
+		}
+
+		sb.WriteString("  }\n")
+	}
+
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (mr *muxRule) dumpName() string {
+	switch {
+	case mr.host != "":
+		return mr.host
+	case mr.hostRegexp != "":
+		return "~ " + mr.hostRegexp
+	}
+	return "*"
+}
+
+func (n *node) dump(sb *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if depth == 1 {
+		sb.WriteString(indent + "root\n")
+	} else {
+		sb.WriteString(indent + n.describe() + "\n")
+	}
+	for _, r := range n.routes {
+		fmt.Fprintf(sb, "%s  route %s\n", indent, r.summary())
+	}
+
+	for _, nds := range n.children {
+		for _, child := range nds {
+			child.dump(sb, depth+1)
+		}
+	}
+}
+
+// describe describes a node, e.g. param "{id}" label='{' tail='/'.
+func (n *node) describe() string {
+	s := fmt.Sprintf("%s %q label=%q", nodeTypeNames[n.typ], n.prefix, n.label)
+	if n.typ != ntStatic {
+		s += fmt.Sprintf(" tail=%q", n.tail)
+	}
+	switch {
+	case n.rex != nil:
+		s += fmt.Sprintf(" regexp=%s", n.rex)
+	case n.check != nil:
+		s += fmt.Sprintf(" type=%s", n.prefix)
+	}
+	return s
+}
+
+// summary describes a route by its methods, pattern and backend.
+func (r *Route) summary() string {
+	s := fmt.Sprintf("%s -> %s", r.pattern, r.backend)
+	if methods := r.methodNames(); methods != nil {
+		s = strings.Join(methods, ",") + " " + s
+	}
+	return s
+}
+
+// paths returns the sorted paths of the cache.
+func (pc PathCache) paths() []string {
+	paths := make([]string, 0, len(pc))
+	for path := range pc {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// dotLabel quotes the lines as a left aligned Graphviz label.
+func dotLabel(lines ...string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, `\`, `\\`)
+		line = strings.ReplaceAll(line, `"`, `\"`)
+		sb.WriteString(line + `\l`)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpTree(t *testing.T) {
+	router := New([]*Rule{
+		{Host: "a.com", Paths: []*Path{
+			{Path: "/search", Backend: "search"},
+			{Path: "/support/{id:int}", Methods: []string{"GET"}, Backend: "int"},
+			{Path: "/support/{topic}/faq", Backend: "faq"},
+			{Path: "/sitemap/*", Backend: "sitemap"},
+		}},
+		{HostRegexp: `^b\.`, Paths: []*Path{{Path: "/", Backend: "b"}}},
+	}, false)
+
+	var sb strings.Builder
+	assert.NoError(t, router.DumpTree(&sb))
+	assert.Equal(t, `rule a.com
+  root
+    static "/s" label='/'
+      static "itemap/" label='i'
+        catchAll "*" label='*' tail='\x00'
+          route /sitemap/* -> sitemap
+      static "upport/" label='u'
+        regexp "int" label='{' tail='/' type=int
+          route GET /support/{id:int} -> int
+        param "{topic}" label='{' tail='/'
+          static "/faq" label='/'
+            route /support/{topic}/faq -> faq
+  path cache
+    /search
+      route /search -> search
+rule ~ ^b\.
+  root
+  path cache
+    /
+      route / -> b
+`, sb.String())
+
+	sb.Reset()
+	assert.NoError(t, router.WriteDOT(&sb))
+	dot := sb.String()
+	assert.True(t, strings.HasPrefix(dot, "digraph router {\n"))
+	assert.Contains(t, dot, `label="rule ~ ^b\\.\l";`)
+	assert.Contains(t, dot, `r0n3 [label="catchAll \"*\" label='*' tail='\\x00'\lroute /sitemap/* -> sitemap\l"];`)
+	assert.Contains(t, dot, "r0n2 -> r0n3;")
+	assert.Contains(t, dot, `r0cache [shape=note, label="path cache\l/search: /search -> search\l"];`)
+}
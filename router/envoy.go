@@ -0,0 +1,519 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	envoyRouteConfiguration struct {
+		VirtualHosts []json.RawMessage `json:"virtual_hosts"`
+	}
+
+	envoyVirtualHost struct {
+		Name    string            `json:"name"`
+		Domains []string          `json:"domains"`
+		Routes  []json.RawMessage `json:"routes"`
+	}
+
+	envoyRoute struct {
+		Name  string          `json:"name"`
+		Match json.RawMessage `json:"match"`
+		Route json.RawMessage `json:"route"`
+	}
+
+	envoyRouteMatch struct {
+		Prefix              *string               `json:"prefix"`
+		Path                *string               `json:"path"`
+		SafeRegex           *envoyRegexMatcher    `json:"safe_regex"`
+		PathSeparatedPrefix *string               `json:"path_separated_prefix"`
+		CaseSensitive       *bool                 `json:"case_sensitive"`
+		Headers             []*envoyHeaderMatcher `json:"headers"`
+		QueryParameters     []*envoyQueryMatcher  `json:"query_parameters"`
+		Grpc                json.RawMessage       `json:"grpc"`
+	}
+
+	envoyRegexMatcher struct {
+		Regex string `json:"regex"`
+	}
+
+	envoyStringMatcher struct {
+		Exact      *string            `json:"exact"`
+		Prefix     *string            `json:"prefix"`
+		Suffix     *string            `json:"suffix"`
+		Contains   *string            `json:"contains"`
+		SafeRegex  *envoyRegexMatcher `json:"safe_regex"`
+		IgnoreCase bool               `json:"ignore_case"`
+	}
+
+	envoyHeaderMatcher struct {
+		Name           string              `json:"name"`
+		ExactMatch     *string             `json:"exact_match"`
+		SafeRegexMatch *envoyRegexMatcher  `json:"safe_regex_match"`
+		RangeMatch     *envoyRangeMatch    `json:"range_match"`
+		PresentMatch   *bool               `json:"present_match"`
+		PrefixMatch    *string             `json:"prefix_match"`
+		SuffixMatch    *string             `json:"suffix_match"`
+		ContainsMatch  *string             `json:"contains_match"`
+		StringMatch    *envoyStringMatcher `json:"string_match"`
+		InvertMatch    bool                `json:"invert_match"`
+	}
+
+	envoyRangeMatch struct {
+		Start json.Number `json:"start"`
+		End   json.Number `json:"end"`
+	}
+
+	envoyQueryMatcher struct {
+		Name         string              `json:"name"`
+		StringMatch  *envoyStringMatcher `json:"string_match"`
+		PresentMatch *bool               `json:"present_match"`
+	}
+
+	envoyRouteAction struct {
+		Cluster          string                 `json:"cluster"`
+		ClusterHeader    string                 `json:"cluster_header"`
+		WeightedClusters *envoyWeightedClusters `json:"weighted_clusters"`
+	}
+
+	envoyWeightedClusters struct {
+		Clusters []struct {
+			Name   string      `json:"name"`
+			Weight json.Number `json:"weight"`
+		} `json:"clusters"`
+	}
+
+	// envoyDomain is a domain of a virtual host translated into a rule.
+	envoyDomain struct {
+		host   string
+		regexp string
+		rank   int // exact, suffix wildcard, prefix wildcard, any
+		length int
+	}
+)
+
+var (
+	envoyConfigFields      = []string{"name", "virtual_hosts"}
+	envoyVirtualHostFields = []string{"name", "domains", "routes"}
+	envoyRouteFields       = []string{"name", "match", "route", "metadata"}
+	envoyMatchFields       = []string{"prefix", "path", "safe_regex", "path_separated_prefix", "case_sensitive", "headers", "query_parameters", "grpc"}
+	envoyActionFields      = []string{"cluster", "cluster_header", "weighted_clusters"}
+)
+
+// ImportEnvoy builds rules from an Envoy v3 RouteConfiguration in JSON, with
+// the field names in snake case.
+//
+// Every domain of a virtual host becomes a rule, the rules are ordered like
+// Envoy selects virtual hosts: exact domains, suffix wildcards, prefix
+// wildcards and finally *, the longer wildcards first. The prefix, path,
+// safe_regex and path_separated_prefix matches become paths, the header and
+// query parameter matchers an expression in vars. The backend is the cluster,
+// the heaviest of weighted clusters or the route name.
+//
+// Envoy picks the first matching route of a virtual host while the router
+// prefers the more specific path, a catch-all route listed before a specific
+// one doesn't shadow it here. A request matching no route of its virtual host
+// falls through to the rules of the less specific domains. Both differences are
+// reported as warnings, as well as the fields that cannot be represented, a
+// route whose match cannot be represented is left out.
+func ImportEnvoy(data []byte) ([]*Rule, []ImportWarning, error) {
+	fields, err := rawFields(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := &envoyRouteConfiguration{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, nil, err
+	}
+
+	warnings := []ImportWarning{}
+	for _, name := range unknownFields(fields, envoyConfigFields) {
+		warnings = append(warnings, ImportWarning{Field: name, Reason: "not supported by the router"})
+	}
+
+	type entry struct {
+		domain envoyDomain
+		name   string
+		vhost  string
+		paths  []*Path
+	}
+	entries := []*entry{}
+
+	for i, raw := range config.VirtualHosts {
+		fields, err := rawFields(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("virtual host #%d: %v", i, err)
+		}
+		vh := &envoyVirtualHost{}
+		if err := json.Unmarshal(raw, vh); err != nil {
+			return nil, nil, fmt.Errorf("virtual host #%d: %v", i, err)
+		}
+		if vh.Name == "" {
+			vh.Name = strconv.Itoa(i)
+		}
+
+		for _, name := range unknownFields(fields, envoyVirtualHostFields) {
+			warnings = append(warnings, ImportWarning{Route: vh.Name, Field: name, Reason: "not supported by the router"})
+		}
+
+		paths, ids := []*Path{}, []string{}
+		for j, raw := range vh.Routes {
+			id := fmt.Sprintf("%s/%d", vh.Name, j)
+			path, err := envoyPath(raw, &id, func(field, reason string) {
+				warnings = append(warnings, ImportWarning{Route: id, Field: field, Reason: reason})
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("route %s: %v", id, err)
+			}
+			if path != nil {
+				paths, ids = append(paths, path), append(ids, id)
+			}
+		}
+
+		for j, later := range paths {
+			for k, earlier := range paths[:j] {
+				if envoyShadows(earlier, later) {
+					warnings = append(warnings, ImportWarning{Route: ids[j], Field: "match", Reason: fmt.Sprintf(
+						"Envoy gives some of its requests to the earlier route %s, the router to this more specific route", ids[k])})
+					break
+				}
+			}
+		}
+
+		for _, d := range vh.Domains {
+			domain, err := parseEnvoyDomain(d)
+			if err != nil {
+				warnings = append(warnings, ImportWarning{Route: vh.Name, Field: "domains", Reason: err.Error()})
+				continue
+			}
+			entries = append(entries, &entry{domain: domain, name: d, vhost: vh.Name, paths: paths})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		di, dj := entries[i].domain, entries[j].domain
+		if di.rank != dj.rank {
+			return di.rank < dj.rank
+		}
+		return di.length > dj.length
+	})
+
+	rules := make([]*Rule, 0, len(entries))
+	for _, e := range entries {
+		rules = append(rules, &Rule{Host: e.domain.host, HostRegexp: e.domain.regexp, Paths: e.paths})
+	}
+
+	for i, e := range entries {
+		if matchesEverything(e.paths) {
+			continue
+		}
+		for j, later := range entries[i+1:] {
+			if later.vhost != e.vhost && hostsOverlap(rules[i], rules[i+1+j]) {
+				warnings = append(warnings, ImportWarning{Route: e.vhost, Field: "domains", Reason: fmt.Sprintf(
+					"a request for %s matching no route falls through to the virtual host %s of %s, Envoy rejects it", e.name, later.vhost, later.name)})
+				break
+			}
+		}
+	}
+
+	return rules, warnings, nil
+}
+
+// parseEnvoyDomain translates a domain of a virtual host, the port is dropped
+// as the router matches the host without it.
+func parseEnvoyDomain(domain string) (envoyDomain, error) {
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		domain = h
+	}
+	domain = strings.ToLower(domain)
+
+	switch {
+	case domain == "*":
+		return envoyDomain{rank: 3}, nil
+	case strings.Count(domain, "*") > 1:
+		return envoyDomain{}, fmt.Errorf("domain %s has more than one wildcard", domain)
+	case strings.HasPrefix(domain, "*"):
+		return envoyDomain{regexp: "^.+" + regexp.QuoteMeta(domain[1:]) + "$", rank: 1, length: len(domain)}, nil
+	case strings.HasSuffix(domain, "*"):
+		return envoyDomain{regexp: "^" + regexp.QuoteMeta(domain[:len(domain)-1]) + ".+$", rank: 2, length: len(domain)}, nil
+	case strings.Contains(domain, "*"):
+		return envoyDomain{}, fmt.Errorf("domain %s has a wildcard in the middle", domain)
+	}
+	return envoyDomain{host: domain}, nil
+}
+
+// envoyShadows reports whether the earlier path may take requests of the later
+// path in Envoy while the router gives them to the later one, that is when a
+// sample path of the later one matches the earlier one. An exact path is
+// preferred by the router too and the same path keeps the order.
+func envoyShadows(earlier, later *Path) bool {
+	if earlier.PathType == PathTypeExact || earlier.Path == later.Path && earlier.PathType == later.PathType {
+		return false
+	}
+
+	var samples []string
+	switch later.PathType {
+	case PathTypeExact:
+		samples = []string{later.Path}
+	case PathTypePrefix:
+		samples = []string{later.Path, strings.TrimSuffix(later.Path, "/") + "/x"}
+	case PathTypeRegularExpression:
+		samples = regexpSamples(later.Path)
+	default:
+		prefix := strings.TrimSuffix(later.Path, "*")
+		samples = []string{prefix, strings.TrimSuffix(prefix, "/") + "/x"}
+	}
+
+	r := newRoute(earlier)
+	for _, s := range samples {
+		if r.matchesPath(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// envoyPath translates a route, it returns a nil path for a route left out.
+// The id is replaced by the route name if there is one.
+func envoyPath(raw json.RawMessage, id *string, warn func(field, reason string)) (*Path, error) {
+	fields, err := rawFields(raw)
+	if err != nil {
+		return nil, err
+	}
+	route := &envoyRoute{}
+	if err := json.Unmarshal(raw, route); err != nil {
+		return nil, err
+	}
+	if route.Name != "" {
+		*id = route.Name
+	}
+
+	for _, name := range unknownFields(fields, envoyRouteFields) {
+		warn(name, "not supported by the router")
+	}
+
+	if fields, err = rawFields(route.Match); err != nil {
+		return nil, fmt.Errorf("match: %v", err)
+	}
+	match := &envoyRouteMatch{}
+	if err := json.Unmarshal(route.Match, match); err != nil {
+		return nil, fmt.Errorf("match: %v", err)
+	}
+
+	if unknown := unknownFields(fields, envoyMatchFields); len(unknown) > 0 {
+		for _, name := range unknown {
+			warn("match."+name, "not supported by the router, the route is left out")
+		}
+		return nil, nil
+	}
+
+	path := &Path{Backend: *id}
+	caseSensitive := match.CaseSensitive == nil || *match.CaseSensitive
+
+	switch {
+	case match.Prefix != nil:
+		prefix := *match.Prefix
+		if caseSensitive && !strings.ContainsAny(prefix, "{}*[]") {
+			path.Path = "/" + strings.TrimPrefix(prefix, "/") + "*"
+		} else {
+			path.Path = envoyRegexp(regexp.QuoteMeta(prefix)+".*", caseSensitive)
+			path.PathType = PathTypeRegularExpression
+		}
+	case match.Path != nil:
+		path.Path = *match.Path
+		path.PathType = PathTypeExact
+		if !caseSensitive || strings.ContainsAny(path.Path, "{}*[]") {
+			path.Path = envoyRegexp(regexp.QuoteMeta(path.Path), caseSensitive)
+			path.PathType = PathTypeRegularExpression
+		}
+	case match.SafeRegex != nil:
+		path.Path = match.SafeRegex.Regex
+		path.PathType = PathTypeRegularExpression
+	case match.PathSeparatedPrefix != nil:
+		path.Path = *match.PathSeparatedPrefix
+		path.PathType = PathTypePrefix
+		if !caseSensitive || strings.ContainsAny(path.Path, "{}*[]") {
+			path.Path = envoyRegexp(regexp.QuoteMeta(path.Path)+"(/.*)?", caseSensitive)
+			path.PathType = PathTypeRegularExpression
+		}
+	default:
+		warn("match", "missing path specifier, the route is left out")
+		return nil, nil
+	}
+
+	if path.PathType == PathTypeRegularExpression {
+		if _, err := regexp.Compile(path.Path); err != nil {
+			warn("match", fmt.Sprintf("invalid regexp: %v, the route is left out", err))
+			return nil, nil
+		}
+	}
+
+	if match.Grpc != nil {
+		path.Vars = append(path.Vars, []interface{}{"http_content_type", "~~", "^application/grpc"})
+	}
+
+	for _, h := range match.Headers {
+		cond, err := envoyHeaderCond(h)
+		if err != nil {
+			warn("match.headers", err.Error()+", the route is left out")
+			return nil, nil
+		}
+		path.Vars = append(path.Vars, cond)
+	}
+
+	for _, q := range match.QueryParameters {
+		cond, err := envoyQueryCond(q)
+		if err != nil {
+			warn("match.query_parameters", err.Error()+", the route is left out")
+			return nil, nil
+		}
+		path.Vars = append(path.Vars, cond)
+	}
+
+	if _, err := compileVars(path.Vars); err != nil {
+		warn("match", err.Error()+", the route is left out")
+		return nil, nil
+	}
+
+	if route.Route == nil {
+		warn("route", "missing route action, the route name is used as backend")
+		return path, nil
+	}
+
+	if fields, err = rawFields(route.Route); err != nil {
+		return nil, fmt.Errorf("route: %v", err)
+	}
+	action := &envoyRouteAction{}
+	if err := json.Unmarshal(route.Route, action); err != nil {
+		return nil, fmt.Errorf("route: %v", err)
+	}
+
+	for _, name := range unknownFields(fields, envoyActionFields) {
+		warn("route."+name, "not supported by the router")
+	}
+
+	switch {
+	case action.Cluster != "":
+		path.Backend = action.Cluster
+	case action.WeightedClusters != nil && len(action.WeightedClusters.Clusters) > 0:
+		var weight int64 = -1
+		for _, c := range action.WeightedClusters.Clusters {
+			w, _ := c.Weight.Int64()
+			if w > weight {
+				path.Backend, weight = c.Name, w
+			}
+		}
+		if len(action.WeightedClusters.Clusters) > 1 {
+			warn("route.weighted_clusters", "traffic splitting is not supported, the heaviest cluster is used as backend")
+		}
+	case action.ClusterHeader != "":
+		warn("route.cluster_header", "not supported by the router, the route name is used as backend")
+	}
+
+	return path, nil
+}
+
+// envoyRegexp makes a path regexp case insensitive if needed, it isn't anchored
+// as path regexps match the whole path anyway.
+func envoyRegexp(re string, caseSensitive bool) string {
+	if caseSensitive {
+		return re
+	}
+	return "(?i)" + re
+}
+
+// envoyHeaderCond translates a header matcher into a vars expression, the
+// pseudo headers :method and :authority map onto request_method and host.
+func envoyHeaderCond(h *envoyHeaderMatcher) ([]interface{}, error) {
+	name := strings.ToLower(h.Name)
+	switch {
+	case name == ":method":
+		name = "request_method"
+	case name == ":authority" || name == "host":
+		name = "host"
+	case strings.HasPrefix(name, ":"):
+		return nil, fmt.Errorf("pseudo header %s is not supported", h.Name)
+	case strings.Contains(name, "_"):
+		return nil, fmt.Errorf("header %s contains an underscore", h.Name)
+	default:
+		name = "http_" + strings.ReplaceAll(name, "-", "_")
+	}
+
+	var cond []interface{}
+
+	switch {
+	case h.ExactMatch != nil:
+		cond = []interface{}{name, "==", *h.ExactMatch}
+	case h.SafeRegexMatch != nil:
+		cond = []interface{}{name, "~~", "^(?:" + h.SafeRegexMatch.Regex + ")$"}
+	case h.PrefixMatch != nil:
+		cond = []interface{}{name, "~~", "^" + regexp.QuoteMeta(*h.PrefixMatch)}
+	case h.SuffixMatch != nil:
+		cond = []interface{}{name, "~~", regexp.QuoteMeta(*h.SuffixMatch) + "$"}
+	case h.ContainsMatch != nil:
+		cond = []interface{}{name, "~~", regexp.QuoteMeta(*h.ContainsMatch)}
+	case h.StringMatch != nil:
+		var err error
+		if cond, err = envoyStringCond(name, h.StringMatch); err != nil {
+			return nil, err
+		}
+	case h.RangeMatch != nil:
+		start, err1 := h.RangeMatch.Start.Float64()
+		end, err2 := h.RangeMatch.End.Float64()
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("header %s has an invalid range", h.Name)
+		}
+		// the range is half open, [start, end)
+		cond = []interface{}{"AND", []interface{}{name, ">=", start}, []interface{}{name, "<", end}}
+	case h.PresentMatch != nil:
+		cond = []interface{}{name, "present", *h.PresentMatch}
+	default:
+		return nil, fmt.Errorf("header %s has an unsupported matcher", h.Name)
+	}
+
+	if h.InvertMatch {
+		cond = []interface{}{"NOT", cond}
+	}
+	return cond, nil
+}
+
+func envoyQueryCond(q *envoyQueryMatcher) ([]interface{}, error) {
+	name := "arg_" + q.Name
+	switch {
+	case q.StringMatch != nil:
+		return envoyStringCond(name, q.StringMatch)
+	case q.PresentMatch != nil && *q.PresentMatch:
+		return []interface{}{name, "present", true}, nil
+	}
+	return nil, fmt.Errorf("query parameter %s has an unsupported matcher", q.Name)
+}
+
+func envoyStringCond(name string, m *envoyStringMatcher) ([]interface{}, error) {
+	op := "~~"
+	if m.IgnoreCase {
+		op = "~*"
+	}
+
+	switch {
+	case m.Exact != nil && !m.IgnoreCase:
+		return []interface{}{name, "==", *m.Exact}, nil
+	case m.Exact != nil:
+		return []interface{}{name, op, "^" + regexp.QuoteMeta(*m.Exact) + "$"}, nil
+	case m.Prefix != nil:
+		return []interface{}{name, op, "^" + regexp.QuoteMeta(*m.Prefix)}, nil
+	case m.Suffix != nil:
+		return []interface{}{name, op, regexp.QuoteMeta(*m.Suffix) + "$"}, nil
+	case m.Contains != nil:
+		return []interface{}{name, op, regexp.QuoteMeta(*m.Contains)}, nil
+	case m.SafeRegex != nil:
+		// ignore_case has no effect on regexps in Envoy
+		return []interface{}{name, "~~", "^(?:" + m.SafeRegex.Regex + ")$"}, nil
+	}
+	return nil, fmt.Errorf("%s has an unsupported string matcher", name)
+}
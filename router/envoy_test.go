@@ -0,0 +1,166 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportEnvoy(t *testing.T) {
+	data := []byte(`{
+		"name": "local_route",
+		"virtual_hosts": [
+			{
+				"name": "fallback",
+				"domains": ["*"],
+				"routes": [{"match": {"prefix": "/"}, "route": {"cluster": "default"}}]
+			},
+			{
+				"name": "api",
+				"domains": ["api.example.com", "api.example.com:8080", "*.example.com"],
+				"require_tls": "ALL",
+				"routes": [
+					{"name": "users", "match": {"path_separated_prefix": "/users"}, "route": {"cluster": "users", "timeout": "5s"}},
+					{"name": "item", "match": {"safe_regex": {"regex": "/items/[0-9]+"}}, "route": {"cluster": "items"}},
+					{"name": "status", "match": {"path": "/Status", "case_sensitive": false}, "direct_response": {"status": 200}},
+					{
+						"name": "canary",
+						"match": {
+							"prefix": "/v1",
+							"headers": [
+								{"name": "x-canary", "string_match": {"exact": "true"}},
+								{"name": ":method", "exact_match": "GET"},
+								{"name": "x-debug", "present_match": true, "invert_match": true}
+							],
+							"query_parameters": [{"name": "lang", "string_match": {"prefix": "en", "ignore_case": true}}]
+						},
+						"route": {"weighted_clusters": {"clusters": [{"name": "v1", "weight": 10}, {"name": "v1-canary", "weight": 90}]}}
+					},
+					{"name": "v1", "match": {"prefix": "/v1"}, "route": {"cluster": "v1"}},
+					{"name": "range", "match": {"prefix": "/shard", "headers": [{"name": "x-shard", "range_match": {"start": "0", "end": 10}}]}, "route": {"cluster": "shard"}},
+					{"name": "runtime", "match": {"prefix": "/beta", "runtime_fraction": {}}, "route": {"cluster": "beta"}},
+					{"name": "grpc", "match": {"prefix": "/grpc.", "grpc": {}}, "route": {"cluster": "grpc"}}
+				]
+			},
+			{
+				"name": "prefix",
+				"domains": ["www.*"],
+				"routes": [{"match": {"prefix": "/"}, "route": {"cluster": "www"}}]
+			}
+		]
+	}`)
+
+	rules, warnings, err := ImportEnvoy(data)
+	assert.Nil(t, err)
+	assert.Len(t, rules, 5)
+	assert.Equal(t, "api.example.com", rules[0].Host)
+	assert.Equal(t, "api.example.com", rules[1].Host)
+	assert.Equal(t, `^.+\.example\.com$`, rules[2].HostRegexp)
+	assert.Equal(t, `^www\..+$`, rules[3].HostRegexp)
+	assert.Equal(t, "", rules[4].Host+rules[4].HostRegexp)
+	assert.Equal(t, []ImportWarning{
+		{Route: "api", Field: "require_tls", Reason: "not supported by the router"},
+		{Route: "users", Field: "route.timeout", Reason: "not supported by the router"},
+		{Route: "status", Field: "direct_response", Reason: "not supported by the router"},
+		{Route: "status", Field: "route", Reason: "missing route action, the route name is used as backend"},
+		{Route: "canary", Field: "route.weighted_clusters", Reason: "traffic splitting is not supported, the heaviest cluster is used as backend"},
+		{Route: "runtime", Field: "match.runtime_fraction", Reason: "not supported by the router, the route is left out"},
+		{Route: "api", Field: "domains", Reason: "a request for api.example.com matching no route falls through to the virtual host fallback of *, Envoy rejects it"},
+		{Route: "api", Field: "domains", Reason: "a request for api.example.com:8080 matching no route falls through to the virtual host fallback of *, Envoy rejects it"},
+		{Route: "api", Field: "domains", Reason: "a request for *.example.com matching no route falls through to the virtual host fallback of *, Envoy rejects it"},
+	}, warnings)
+
+	tests := []struct {
+		m       string
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{m: "GET", r: "http://api.example.com/users", h: "users"},
+		{m: "GET", r: "http://api.example.com/users/1", h: "users"},
+		{m: "GET", r: "http://api.example.com/usersx", h: "default"},
+		{m: "GET", r: "http://other.com/usersx", h: "default"},
+		{m: "GET", r: "http://a.example.com/items/12", h: "items"},
+		{m: "GET", r: "http://a.example.com/items/x", h: "default"},
+		{m: "GET", r: "http://api.example.com/STATUS", h: "status"},
+		{m: "GET", r: "http://api.example.com/v1/a?lang=EN-us", h: "v1-canary", headers: map[string]string{"X-Canary": "true"}},
+		{m: "POST", r: "http://api.example.com/v1/a?lang=en", h: "v1", headers: map[string]string{"X-Canary": "true"}},
+		{m: "GET", r: "http://api.example.com/v1/a?lang=en", h: "v1", headers: map[string]string{"X-Canary": "true", "X-Debug": "1"}},
+		{m: "GET", r: "http://api.example.com/v1/a?lang=en", h: "v1", headers: map[string]string{"X-Canary": "true", "X-Debug": ""}},
+		{m: "GET", r: "http://api.example.com/v1/a?lang=fr", h: "v1", headers: map[string]string{"X-Canary": "true"}},
+		{m: "GET", r: "http://api.example.com/shard", h: "shard", headers: map[string]string{"X-Shard": "9"}},
+		{m: "GET", r: "http://api.example.com/shard", h: "default", headers: map[string]string{"X-Shard": "10"}},
+		{m: "GET", r: "http://api.example.com/beta", h: "default"},
+		{m: "POST", r: "http://api.example.com/grpc.Service/Call", h: "grpc", headers: map[string]string{"Content-Type": "application/grpc+proto"}},
+		{m: "GET", r: "http://www.example.org/x", h: "www"},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s %v", tt.m, tt.r, tt.headers)
+	}
+}
+
+func TestImportEnvoyShadowing(t *testing.T) {
+	data := []byte(`{"virtual_hosts": [{
+		"name": "vh",
+		"domains": ["*"],
+		"routes": [
+			{"name": "api", "match": {"prefix": "/api"}, "route": {"cluster": "api"}},
+			{"name": "v2", "match": {"prefix": "/api/v2"}, "route": {"cluster": "v2"}},
+			{"name": "health", "match": {"path": "/api/health"}, "route": {"cluster": "health"}},
+			{"name": "files", "match": {"safe_regex": {"regex": "/files/.*"}}, "route": {"cluster": "files"}},
+			{"name": "a", "match": {"path_separated_prefix": "/files/a"}, "route": {"cluster": "a"}},
+			{"name": "again", "match": {"prefix": "/api"}, "route": {"cluster": "again"}}
+		]
+	}]}`)
+
+	_, warnings, err := ImportEnvoy(data)
+	assert.Nil(t, err)
+
+	reason := "Envoy gives some of its requests to the earlier route %s, the router to this more specific route"
+	assert.Equal(t, []ImportWarning{
+		{Route: "v2", Field: "match", Reason: fmt.Sprintf(reason, "api")},
+		{Route: "health", Field: "match", Reason: fmt.Sprintf(reason, "api")},
+		{Route: "a", Field: "match", Reason: fmt.Sprintf(reason, "files")},
+	}, warnings)
+}
+
+func TestParseEnvoyDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		host   string
+		re     string
+		err    bool
+	}{
+		{domain: "Example.com", host: "example.com"},
+		{domain: "example.com:443", host: "example.com"},
+		{domain: "*-bar.foo.com", re: `^.+-bar\.foo\.com$`},
+		{domain: "foo.*", re: `^foo\..+$`},
+		{domain: "*"},
+		{domain: "a.*.com", err: true},
+		{domain: "*.a.*", err: true},
+	}
+
+	for _, tt := range tests {
+		d, err := parseEnvoyDomain(tt.domain)
+		assert.Equal(t, tt.err, err != nil, tt.domain)
+		assert.Equal(t, tt.host, d.host, tt.domain)
+		assert.Equal(t, tt.re, d.regexp, tt.domain)
+	}
+}
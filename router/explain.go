@@ -0,0 +1,282 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The kinds of trace steps.
+const (
+	// TraceCache is a lookup of the path in the path cache
+	TraceCache = "cache"
+	// TraceNode is a node of the tree visited
+	TraceNode = "node"
+	// TraceRoute is a route tried
+	TraceRoute = "route"
+)
+
+var nodeTypeNames = [...]string{
+	ntStatic:     "static",
+	ntRegexp:     "regexp",
+	ntParam:      "param",
+	ntMultiParam: "multiParam",
+	ntCatchAll:   "catchAll",
+}
+
+type (
+	// Explanation traces a search, see SearchExplain.
+	Explanation struct {
+		Method string
+		Host   string
+		Path   string
+		// Rules are the rules considered, in order
+		Rules []*RuleTrace
+		// Route is the route found, nil if none
+		Route *Route
+	}
+
+	// RuleTrace traces the search of a rule.
+	RuleTrace struct {
+		// Host is the host or the host regexp of the rule, empty for any host
+		Host    string
+		Matched bool
+		// Reason tells why the host of the request matched or not
+		Reason string
+		// Steps are the path cache lookup, the nodes visited and the routes
+		// tried, in order
+		Steps []*TraceStep
+	}
+
+	// TraceStep is a step of the search of a rule.
+	TraceStep struct {
+		Kind string
+		// Depth is the depth of the node in the tree, 0 for the path cache
+		Depth int
+		// Detail describes the node or the route, e.g. param {id} = "1"
+		Detail string
+		// Route is the route of a TraceRoute step
+		Route   *Route
+		Matched bool
+		// Reason tells why the node or the route was rejected, e.g. the
+		// predicate of the route that didn't match
+		Reason string
+	}
+
+	searchTrace struct {
+		exp     *Explanation
+		current *RuleTrace
+		depth   int
+	}
+)
+
+// SearchExplain searches the request like Search and explains the search: the
+// rules considered and why their host matched or not, the nodes visited, the
+// routes tried and the predicate that rejected them, and the route found.
+func (ar *ArtRouter) SearchExplain(req *http.Request) (*Context, *Explanation) {
+	context := newContext(req)
+	exp := &Explanation{Method: req.Method, Host: req.Host, Path: req.URL.Path, Rules: []*RuleTrace{}}
+	context.trace = &searchTrace{exp: exp}
+
+	ar.search(context)
+
+	context.trace = nil
+	exp.Route = context.Route
+	return context, exp
+}
+
+func (t *searchTrace) rule(mr *muxRule, host string, matched bool) {
+	if t == nil {
+		return
+	}
+
+	reason := ""
+	switch {
+	case mr.host == "" && mr.hostRE == nil:
+		reason = "any host"
+	case matched && mr.host == host:
+		reason = fmt.Sprintf("host %s", host)
+	case matched:
+		reason = fmt.Sprintf("host %s matches %s", host, mr.hostRegexp)
+	case mr.host != "" && mr.hostRE != nil:
+		reason = fmt.Sprintf("host %s is not %s and does not match %s", host, mr.host, mr.hostRegexp)
+	case mr.host != "":
+		reason = fmt.Sprintf("host %s is not %s", host, mr.host)
+	default:
+		reason = fmt.Sprintf("host %s does not match %s", host, mr.hostRegexp)
+	}
+
+	t.current = &RuleTrace{Host: mr.describeHost(), Matched: matched, Reason: reason, Steps: []*TraceStep{}}
+	t.exp.Rules = append(t.exp.Rules, t.current)
+}
+
+func (t *searchTrace) cache(path string, hit bool) {
+	if t == nil {
+		return
+	}
+	step := &TraceStep{Kind: TraceCache, Detail: path, Matched: hit}
+	if !hit {
+		step.Reason = "not cached"
+	}
+	t.current.Steps = append(t.current.Steps, step)
+}
+
+// node records a node, value is the part of the path captured by a param node
+// and reason is empty if the node matched.
+func (t *searchTrace) node(n *node, value, reason string) {
+	if t == nil {
+		return
+	}
+	detail := fmt.Sprintf("%s %s", nodeTypeNames[n.typ], n.prefix)
+	if n.typ != ntStatic {
+		detail += fmt.Sprintf(" = %q", value)
+	}
+	t.current.Steps = append(t.current.Steps, &TraceStep{
+		Kind:    TraceNode,
+		Depth:   t.depth,
+		Detail:  detail,
+		Matched: reason == "",
+		Reason:  reason,
+	})
+}
+
+// route records a route tried, q is its match quality, 0 if rejected.
+func (t *searchTrace) route(context *Context, r *Route, q float64) {
+	if t == nil {
+		return
+	}
+	step := &TraceStep{
+		Kind:    TraceRoute,
+		Depth:   t.depth,
+		Detail:  fmt.Sprintf("%s -> %s", r.pattern, r.backend),
+		Route:   r,
+		Matched: q > 0,
+	}
+	if q == 0 {
+		step.Reason = r.rejection(context)
+	} else if r.negotiates() {
+		step.Detail += fmt.Sprintf(" (quality %g)", q)
+	}
+	t.current.Steps = append(t.current.Steps, step)
+}
+
+// rejection describes the first predicate of the route rejecting the request,
+// in the order of Route.match.
+func (r *Route) rejection(context *Context) string {
+	req := context.request
+
+	if context.method&r.method == 0 {
+		return fmt.Sprintf("method %s not in %s", req.Method, strings.Join(r.methodNames(), ", "))
+	}
+
+	if r.pathRE != nil && !r.pathRE.MatchString(context.path) {
+		return fmt.Sprintf("path does not match %s", r.pathRE)
+	}
+
+	if len(r.headers) > 0 && !r.matchHeaders(context.GetHeaders()) {
+		if !r.matchAllHeader {
+			keys := make([]string, 0, len(r.headers))
+			for _, h := range r.headers {
+				keys = append(keys, h.Key)
+			}
+			return fmt.Sprintf("none of the headers %s", strings.Join(keys, ", "))
+		}
+		for _, h := range r.headers {
+			if !(&Route{headers: []*Header{h}, matchAllHeader: true}).matchHeaders(context.GetHeaders()) {
+				return fmt.Sprintf("header %s = %q", h.Key, context.GetHeaders().Get(h.Key))
+			}
+		}
+	}
+
+	for _, q := range r.queries {
+		if !(&Route{queries: []*Query{q}}).matchQueries(context.GetQueries()) {
+			return fmt.Sprintf("query %s = %q", q.Key, context.GetQueries().Get(q.Key))
+		}
+	}
+
+	if r.vars != nil && !r.vars.eval(context) {
+		return "vars " + varsRejection(r.vars, context)
+	}
+
+	for i, m := range r.matchers {
+		if !m.Match(context) {
+			pm := r.path.Matchers[i]
+			if len(pm.Args) > 0 {
+				return fmt.Sprintf("matcher %s %s", pm.Matcher, pm.Args)
+			}
+			return fmt.Sprintf("matcher %s", pm.Matcher)
+		}
+	}
+
+	if len(r.consumes) > 0 && !r.matchContentType(context) {
+		return fmt.Sprintf("content type %q", req.Header.Get("Content-Type"))
+	}
+
+	if r.negotiates() && r.quality(context) == 0 {
+		return "not acceptable"
+	}
+
+	return ""
+}
+
+// varsRejection describes the sub expression of the vars rejecting the
+// request, a comparison with the value of its variable.
+func varsRejection(x expr, context *Context) string {
+	switch e := x.(type) {
+	case exprAnd:
+		for _, sub := range e {
+			if !sub.eval(context) {
+				return varsRejection(sub, context)
+			}
+		}
+	case exprOr:
+		subs := make([]string, 0, len(e))
+		for _, sub := range e {
+			subs = append(subs, varsRejection(sub, context))
+		}
+		return "none of " + strings.Join(subs, "; ")
+	case *exprNot:
+		if cmp, ok := e.x.(*exprCmp); ok {
+			return fmt.Sprintf("%s, %s = %q", e, cmp.variable.source, cmp.variable.value(context))
+		}
+	case *exprCmp:
+		return fmt.Sprintf("%s, %s = %q", e, e.variable.source, e.variable.value(context))
+	}
+	return fmt.Sprint(x)
+}
+
+// String renders the explanation as indented text.
+func (e *Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s %s\n", e.Method, e.Host, e.Path)
+
+	for _, rt := range e.Rules {
+		host := rt.Host
+		if host == "" {
+			host = "*"
+		}
+		fmt.Fprintf(&sb, "rule %s: %s", host, rt.Reason)
+		if !rt.Matched {
+			sb.WriteString(" (skipped)")
+		}
+		sb.WriteString("\n")
+
+		for _, s := range rt.Steps {
+			fmt.Fprintf(&sb, "%s%s %s", strings.Repeat("  ", s.Depth+1), s.Kind, s.Detail)
+			switch {
+			case !s.Matched:
+				fmt.Fprintf(&sb, ": rejected, %s", s.Reason)
+			case s.Kind != TraceNode:
+				sb.WriteString(": matched")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if e.Route == nil {
+		sb.WriteString("no route found\n")
+	} else {
+		fmt.Fprintf(&sb, "route %s -> %s\n", e.Route.pattern, e.Route.backend)
+	}
+	return sb.String()
+}
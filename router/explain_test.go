@@ -0,0 +1,98 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchExplain(t *testing.T) {
+	router := New([]*Rule{
+		{Host: "b.com", Paths: []*Path{{Path: "/", Backend: "b"}}},
+		{Paths: []*Path{
+			{Path: "/users/{id:int}", Backend: "int"},
+			{Path: "/users/{id}", Methods: []string{"POST"}, Backend: "post"},
+			{Path: "/users/{id}", Headers: []*Header{{Key: "X-Version", Values: []string{"2"}}}, MatchAllHeader: true, Backend: "v2"},
+			{Path: "/users/{id}", Backend: "user"},
+			{Path: "/users/me", Queries: []*Query{{Key: "fields", Values: []string{"all"}}}, Backend: "me"},
+		}},
+	}, false)
+
+	req, _ := http.NewRequest("GET", "http://a.com/users/me", nil)
+	context, exp := router.SearchExplain(req)
+	assert.Equal(t, "user", context.Route.backend)
+	assert.Equal(t, "me", context.GetParam("id"))
+	assert.Equal(t, context.Route, exp.Route)
+
+	assert.Equal(t, `GET a.com /users/me
+rule b.com: host a.com is not b.com (skipped)
+rule *: any host
+  cache /users/me: matched
+  route /users/me -> me: rejected, query fields = ""
+    node static /users/
+      node regexp int = "me": rejected, not of type int
+      node param {id} = "me"
+      route /users/{id} -> post: rejected, method GET not in POST
+      route /users/{id} -> v2: rejected, header X-Version = ""
+      route /users/{id} -> user: matched
+route /users/{id} -> user
+`, exp.String())
+
+	// the search is the same as Search
+	req, _ = http.NewRequest("PUT", "http://b.com/users/1", nil)
+	context, exp = router.SearchExplain(req)
+	assert.Equal(t, router.Search(req).Route, context.Route)
+	assert.Equal(t, "int", context.Route.backend)
+	assert.Len(t, exp.Rules, 2)
+	assert.Equal(t, "host b.com", exp.Rules[0].Reason)
+	assert.Equal(t, []*TraceStep{{Kind: TraceCache, Detail: "/users/1", Reason: "not cached"}}, exp.Rules[0].Steps)
+
+	req, _ = http.NewRequest("GET", "http://a.com/nope", nil)
+	_, exp = router.SearchExplain(req)
+	assert.Nil(t, exp.Route)
+	assert.Contains(t, exp.String(), "no route found\n")
+}
+
+func TestRouteRejection(t *testing.T) {
+	RegisterMatcher("explain-never", func(json.RawMessage) (Matcher, error) {
+		return MatcherFunc(func(*Context) bool { return false }), nil
+	})
+
+	tests := []struct {
+		path   *Path
+		header http.Header
+		reason string
+	}{
+		{path: &Path{Methods: []string{"GET", "HEAD"}}, reason: "method POST not in GET, HEAD"},
+		{path: &Path{PathType: PathTypeRegularExpression, Path: "/b.*"}, reason: "path does not match ^(?:/b.*)$"},
+		{path: &Path{Headers: []*Header{{Key: "A", Values: []string{"1"}}, {Key: "B", Regexp: "^x"}}}, reason: "none of the headers A, B"},
+		{path: &Path{Headers: []*Header{{Key: "A", Values: []string{"1"}}, {Key: "B", Regexp: "^x"}}, MatchAllHeader: true}, header: http.Header{"A": {"1"}, "B": {"y"}}, reason: `header B = "y"`},
+		{path: &Path{Queries: []*Query{{Key: "q", Regexp: "^[0-9]+$"}}}, reason: `query q = "x"`},
+		{path: &Path{Vars: []interface{}{[]interface{}{"arg_q", "==", "y"}}}, reason: `vars arg_q == "y", arg_q = "x"`},
+		{path: &Path{Vars: []interface{}{[]interface{}{"arg_q", "==", "x"}, []interface{}{"http_x_env", "!", "~~", "^$"}}}, reason: `vars http_x_env ! ~~ "^$", http_x_env = ""`},
+		{path: &Path{Vars: []interface{}{[]interface{}{"OR", []interface{}{"arg_a", "has", "1"}, []interface{}{"arg_q", "in", []interface{}{"y", "z"}}}}}, reason: `vars none of arg_a has "1", arg_a = ""; arg_q in ["y","z"], arg_q = "x"`},
+		{path: &Path{Vars: []interface{}{[]interface{}{"NOT", []interface{}{"arg_q", "==", "x"}}}}, reason: `vars NOT(arg_q == "x"), arg_q = "x"`},
+		{path: &Path{Matchers: []*PathMatcher{{Matcher: "explain-never"}}}, reason: "matcher explain-never"},
+		{path: &Path{Matchers: []*PathMatcher{{Matcher: "explain-never", Args: json.RawMessage(`{"n":1}`)}}}, reason: `matcher explain-never {"n":1}`},
+		{path: &Path{Consumes: []string{"application/json"}}, header: http.Header{"Content-Type": {"text/plain"}}, reason: `content type "text/plain"`},
+		{path: &Path{Produces: []string{"application/json"}}, header: http.Header{"Accept": {"text/html"}}, reason: "not acceptable"},
+	}
+
+	for _, tt := range tests {
+		if tt.path.Path == "" {
+			tt.path.Path = "/a"
+		}
+		tt.path.Backend = "a"
+		r := newRoute(tt.path)
+
+		req, _ := http.NewRequest("POST", "http://a.com/a?q=x", nil)
+		if tt.header != nil {
+			req.Header = tt.header
+		}
+		context := newContext(req)
+		assert.False(t, r.match(context), tt.reason)
+		assert.Equal(t, tt.reason, r.rejection(context))
+	}
+}
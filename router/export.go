@@ -0,0 +1,290 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type (
+	// RouteInfo describes a route of a built router.
+	RouteInfo struct {
+		// Host is the host or the host regexp of the rule, empty for any host
+		Host string
+		// Methods is nil for any method
+		Methods  []string
+		Pattern  string
+		PathType string
+		Backend  string
+		// Predicates describe the other conditions of the route, e.g. headers
+		Predicates []string
+	}
+
+	openAPIExport struct {
+		OpenAPI string                            `json:"openapi"`
+		Info    openAPIInfo                       `json:"info"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+
+	openAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+)
+
+// RouteTable returns the routes rule by rule, the rules in the order they are
+// tried and the routes of a rule in the order they are declared. This is not
+// the order the router tries the routes of a rule: the static paths of the path
+// cache come first and the tree prefers static segments to params, only the
+// routes of the same pattern are tried in the order of the table.
+func (ar *ArtRouter) RouteTable() []RouteInfo {
+	infos := []RouteInfo{}
+	for _, mr := range ar.rules {
+		host := mr.host
+		if host == "" {
+			host = mr.hostRegexp
+		}
+		for _, r := range mr.routes {
+			infos = append(infos, RouteInfo{
+				Host:       host,
+				Methods:    r.methodNames(),
+				Pattern:    r.pattern,
+				PathType:   r.pathType,
+				Backend:    r.backend,
+				Predicates: r.predicates(),
+			})
+		}
+	}
+	return infos
+}
+
+// WriteRouteTable writes the route table as aligned text columns, any host and
+// any method are shown as *.
+func (ar *ArtRouter) WriteRouteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tMETHODS\tPATTERN\tBACKEND\tPREDICATES")
+
+	for _, info := range ar.RouteTable() {
+		host, methods, pattern := info.Host, "*", info.Pattern
+		if host == "" {
+			host = "*"
+		}
+		if info.Methods != nil {
+			methods = strings.Join(info.Methods, ",")
+		}
+		if info.PathType != "" && info.PathType != PathTypeImplementationSpecific {
+			pattern += " (" + info.PathType + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", host, methods, pattern, info.Backend, strings.Join(info.Predicates, "; "))
+	}
+
+	return tw.Flush()
+}
+
+// OpenAPI returns an OpenAPI 3 skeleton of the routes in JSON with the paths,
+// operations and path params, the backend of an operation is in x-backend and
+// the host of host specific routes in x-host.
+//
+// A route with optional parts has a path for every variant, wildcards become
+// params named after the wildcard or "wildcard" for *. Regular expression paths
+// cannot be expressed and are left out. The conditions of a route besides host,
+// method and path are in x-predicates. The first route of a path and method, in
+// the order of the route table, is the operation, the routes of other hosts or
+// with other predicates are listed in its x-variants with their x-host,
+// x-backend, x-predicates and parameters.
+func (ar *ArtRouter) OpenAPI(title, version string) ([]byte, error) {
+	doc := &openAPIExport{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: title, Version: version},
+		Paths:   map[string]map[string]interface{}{},
+	}
+
+	for _, mr := range ar.rules {
+		host := mr.host
+		if host == "" {
+			host = mr.hostRegexp
+		}
+
+		for _, r := range mr.routes {
+			if r.pathType == PathTypeRegularExpression {
+				continue
+			}
+
+			methods := r.methodNames()
+			if methods == nil {
+				methods = []string{"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE"}
+			}
+
+			for _, pattern := range r.patterns {
+				template, params := openAPITemplate(pattern)
+
+				item, ok := doc.Paths[template]
+				if !ok {
+					item = map[string]interface{}{}
+					doc.Paths[template] = item
+				}
+
+				for _, m := range methods {
+					m = strings.ToLower(m)
+					if m == "connect" {
+						continue
+					}
+
+					op := map[string]interface{}{"x-backend": r.backend}
+					if len(params) > 0 {
+						op["parameters"] = params
+					}
+					if host != "" {
+						op["x-host"] = host
+					}
+					if predicates := r.predicates(); len(predicates) > 0 {
+						op["x-predicates"] = predicates
+					}
+
+					if first, ok := item[m].(map[string]interface{}); ok {
+						variants, _ := first["x-variants"].([]map[string]interface{})
+						first["x-variants"] = append(variants, op)
+						continue
+					}
+
+					op["responses"] = map[string]interface{}{
+						"default": map[string]string{"description": "Response of the backend"},
+					}
+					item[m] = op
+				}
+			}
+		}
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// openAPITemplate converts a pattern without optional parts into a path
+// template and the path params.
+func openAPITemplate(pattern string) (string, []map[string]interface{}) {
+	var sb strings.Builder
+	params := []map[string]interface{}{}
+
+	for {
+		seg := patNextSegment(pattern)
+		if seg.nodeType == ntStatic {
+			sb.WriteString(pattern)
+			return sb.String(), params
+		}
+
+		sb.WriteString(pattern[:seg.ps])
+		pattern = pattern[seg.pe:]
+
+		name := seg.key
+		if name == "*" {
+			name = "wildcard"
+		}
+		sb.WriteString("{" + name + "}")
+
+		schema := map[string]interface{}{"type": "string"}
+		switch {
+		case seg.nodeType == ntMultiParam || seg.nodeType == ntCatchAll:
+			schema["description"] = "may span several path segments"
+		case seg.check != nil && seg.rexpat == "int":
+			schema["type"] = "integer"
+		case seg.check != nil && seg.rexpat == "uint":
+			schema["type"] = "integer"
+			schema["minimum"] = 0
+		case seg.check != nil:
+			// a named param type, e.g. uuid or date
+			schema["format"] = seg.rexpat
+		case seg.rexpat != "":
+			schema["pattern"] = seg.rexpat
+		}
+
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+}
+
+// methodNames returns the sorted methods of the route, nil for any method.
+func (r *Route) methodNames() []string {
+	if r.method == mALL {
+		return nil
+	}
+	names := []string{}
+	for name, m := range methodMap {
+		if r.method&m != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// predicates describes the conditions of the route besides host, method and path.
+func (r *Route) predicates() []string {
+	res := []string{}
+
+	headers := make([]string, 0, len(r.headers))
+	for _, h := range r.headers {
+		headers = append(headers, describeValueMatch("header "+h.Key, h.Values, h.Regexp, r.matchAllHeader))
+	}
+	switch {
+	case len(headers) == 0:
+	case r.matchAllHeader || len(headers) == 1:
+		res = append(res, headers...)
+	default:
+		res = append(res, strings.Join(headers, " or "))
+	}
+
+	for _, q := range r.queries {
+		res = append(res, describeValueMatch("query "+q.Key, q.Values, q.Regexp, true))
+	}
+
+	if p := r.path; p != nil {
+		if len(p.Vars) > 0 {
+			vars, _ := json.Marshal(p.Vars)
+			res = append(res, "vars "+string(vars))
+		}
+		for _, m := range p.Matchers {
+			s := "matcher " + m.Matcher
+			if len(m.Args) > 0 {
+				s += " " + string(m.Args)
+			}
+			res = append(res, s)
+		}
+		if len(p.Consumes) > 0 {
+			res = append(res, "consumes "+strings.Join(p.Consumes, ", "))
+		}
+		if len(p.Produces) > 0 {
+			res = append(res, "produces "+strings.Join(p.Produces, ", "))
+		}
+		if len(p.Languages) > 0 {
+			res = append(res, "languages "+strings.Join(p.Languages, ", "))
+		}
+	}
+
+	return res
+}
+
+// describeValueMatch describes the values and the regexp of a header or query,
+// all tells whether both must match.
+func describeValueMatch(name string, values []string, re string, all bool) string {
+	conds := []string{}
+	if len(values) > 0 {
+		conds = append(conds, fmt.Sprintf("%s in [%s]", name, strings.Join(values, ", ")))
+	}
+	if re != "" {
+		conds = append(conds, fmt.Sprintf("%s ~ %s", name, re))
+	}
+	if len(conds) == 0 {
+		return name
+	}
+	if all {
+		return strings.Join(conds, " and ")
+	}
+	return strings.Join(conds, " or ")
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testExportRouter() ArtRouter {
+	return New([]*Rule{
+		{
+			Host: "api.example.com",
+			Paths: []*Path{
+				{Path: "/users/{id:int}[/{tab}]", Methods: []string{"GET", "HEAD"}, Backend: "users"},
+				{
+					Path:           "/users/{id:uuid}",
+					Backend:        "users-v2",
+					Headers:        []*Header{{Key: "X-Version", Values: []string{"2"}}, {Key: "X-Beta", Regexp: "^on$"}},
+					MatchAllHeader: true,
+					Queries:        []*Query{{Key: "page", Regexp: "^[0-9]+$"}},
+				},
+			},
+		},
+		{
+			Paths: []*Path{
+				{Path: "/static", PathType: PathTypePrefix, Backend: "static"},
+				{Path: "/files/{path*}", Methods: []string{"GET"}, Backend: "files", Produces: []string{"application/json"}},
+				{Path: "/v[0-9]+/.*", PathType: PathTypeRegularExpression, Backend: "versioned", Vars: []interface{}{[]interface{}{"arg_x", "==", "1"}}},
+			},
+		},
+	}, false)
+}
+
+func TestRouteTable(t *testing.T) {
+	router := testExportRouter()
+
+	assert.Equal(t, []RouteInfo{
+		{Host: "api.example.com", Methods: []string{"GET", "HEAD"}, Pattern: "/users/{id:int}[/{tab}]", Backend: "users", Predicates: []string{}},
+		{Host: "api.example.com", Pattern: "/users/{id:uuid}", Backend: "users-v2", Predicates: []string{
+			"header X-Version in [2]", "header X-Beta ~ ^on$", "query page ~ ^[0-9]+$",
+		}},
+		{Pattern: "/static", PathType: PathTypePrefix, Backend: "static", Predicates: []string{}},
+		{Methods: []string{"GET"}, Pattern: "/files/{path*}", Backend: "files", Predicates: []string{"produces application/json"}},
+		{Pattern: "/v[0-9]+/.*", PathType: PathTypeRegularExpression, Backend: "versioned", Predicates: []string{`vars [["arg_x","==","1"]]`}},
+	}, router.RouteTable())
+
+	var buf bytes.Buffer
+	assert.Nil(t, router.WriteRouteTable(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 6)
+	assert.Equal(t, "HOST             METHODS   PATTERN                          BACKEND    PREDICATES", lines[0])
+	assert.Equal(t, "*                *         /static (Prefix)                 static", strings.TrimSpace(lines[3]))
+}
+
+func TestExportOpenAPI(t *testing.T) {
+	router := testExportRouter()
+
+	data, err := router.OpenAPI("gateway", "1.0")
+	assert.Nil(t, err)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			Backend    string   `json:"x-backend"`
+			Host       string   `json:"x-host"`
+			Predicates []string `json:"x-predicates"`
+			Parameters []struct {
+				Name   string                 `json:"name"`
+				Schema map[string]interface{} `json:"schema"`
+			} `json:"parameters"`
+			Variants []struct {
+				Backend    string   `json:"x-backend"`
+				Host       string   `json:"x-host"`
+				Predicates []string `json:"x-predicates"`
+			} `json:"x-variants"`
+		} `json:"paths"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, "gateway", doc.Info.Title)
+
+	paths := []string{}
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	assert.ElementsMatch(t, []string{"/users/{id}/{tab}", "/users/{id}", "/static", "/static/{wildcard}", "/files/{path}"}, paths)
+
+	users := doc.Paths["/users/{id}"]
+	assert.Len(t, users, 8)
+	assert.Equal(t, "users", users["get"].Backend)
+	assert.Equal(t, "api.example.com", users["get"].Host)
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, users["get"].Parameters[0].Schema)
+	assert.Nil(t, users["get"].Predicates)
+	assert.Len(t, users["get"].Variants, 1)
+	assert.Equal(t, "users-v2", users["get"].Variants[0].Backend)
+	assert.Equal(t, "api.example.com", users["get"].Variants[0].Host)
+	assert.Equal(t, []string{"header X-Version in [2]", "header X-Beta ~ ^on$", "query page ~ ^[0-9]+$"}, users["get"].Variants[0].Predicates)
+	assert.Equal(t, "users-v2", users["post"].Backend)
+	assert.Empty(t, users["post"].Variants)
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "uuid"}, users["post"].Parameters[0].Schema)
+
+	files := doc.Paths["/files/{path}"]
+	assert.Len(t, files, 1)
+	assert.Equal(t, "path", files["get"].Parameters[0].Name)
+	assert.Equal(t, "", files["get"].Host)
+	assert.Equal(t, []string{"produces application/json"}, files["get"].Predicates)
+}
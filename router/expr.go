@@ -0,0 +1,507 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expressions are compatible in spirit with the vars of lua-resty-radixtree,
+// a list of expressions is evaluated as AND:
+//
+//	[["arg_name", "==", "json"], ["http_x_env", "~~", "^v2"]]
+//
+// Logical expressions start with one of AND, OR, !AND, !OR or NOT followed by
+// the sub expressions:
+//
+//	["OR", ["http_x", "==", "a"], ["arg_y", "~~", "b"]]
+//
+// A comparison is [var, op, value] or [var, "!", op, value] for the negation,
+// the supported operators are ==, ~=, >, >=, <, <=, ~~, ~*, in, has, ipmatch
+// and present. ipmatch takes an IP, a CIDR or a list of them, present takes a
+// boolean and tells whether the variable is in the request, even empty. The
+// value of >, >=, < and <= is a number or a decimal string, like tonumber in
+// lua-resty-expr, and so must be the variable to compare.
+//
+// The supported variables are arg_<name> (query), http_<name> (header, the
+// underscores are replaced by dashes), cookie_<name>, uri, host, request_method
+// and remote_addr. A missing variable evaluates to an empty string.
+
+type (
+	expr interface {
+		eval(context *Context) bool
+	}
+
+	exprAnd []expr
+	exprOr  []expr
+	exprNot struct {
+		x expr
+	}
+
+	varKind uint8
+
+	exprVar struct {
+		name string
+		kind varKind
+		// source is the variable as written, e.g. http_x_env
+		source string
+	}
+
+	exprCmp struct {
+		re       *regexp.Regexp
+		nets     []*net.IPNet
+		str      string
+		set      []string
+		variable exprVar
+		op       string
+		src      string // the comparison as written, for the explanations
+		num      float64
+		isNum    bool
+		negate   bool
+	}
+)
+
+const (
+	varArg varKind = iota
+	varHTTP
+	varCookie
+	varURI
+	varHost
+	varMethod
+	varRemoteAddr
+)
+
+func (e exprAnd) String() string {
+	return "AND(" + joinExprs(e) + ")"
+}
+
+func (e exprOr) String() string {
+	return "OR(" + joinExprs(e) + ")"
+}
+
+func (e *exprNot) String() string {
+	return fmt.Sprintf("NOT(%s)", e.x)
+}
+
+func (e *exprCmp) String() string {
+	return e.src
+}
+
+func joinExprs(list []expr) string {
+	subs := make([]string, 0, len(list))
+	for _, x := range list {
+		subs = append(subs, fmt.Sprint(x))
+	}
+	return strings.Join(subs, ", ")
+}
+
+func (e exprAnd) eval(context *Context) bool {
+	for _, x := range e {
+		if !x.eval(context) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e exprOr) eval(context *Context) bool {
+	for _, x := range e {
+		if x.eval(context) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *exprNot) eval(context *Context) bool {
+	return !e.x.eval(context)
+}
+
+func (e *exprCmp) eval(context *Context) bool {
+	return e.compare(context) != e.negate
+}
+
+func (e *exprCmp) compare(context *Context) bool {
+	switch e.op {
+	case "has":
+		return StrInSlice(e.str, e.variable.values(context))
+	case "present":
+		return e.variable.present(context)
+	case "in":
+		return StrInSlice(e.variable.value(context), e.set)
+	case "~~", "~*":
+		return e.re.MatchString(e.variable.value(context))
+	case "ipmatch":
+		ip := net.ParseIP(e.variable.value(context))
+		if ip == nil {
+			return false
+		}
+		for _, n := range e.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	v := e.variable.value(context)
+
+	if e.isNum {
+		n, ok := parseNumber(v)
+		if !ok {
+			return e.op == "~="
+		}
+		switch e.op {
+		case "==":
+			return n == e.num
+		case "~=":
+			return n != e.num
+		case ">":
+			return n > e.num
+		case ">=":
+			return n >= e.num
+		case "<":
+			return n < e.num
+		case "<=":
+			return n <= e.num
+		}
+		return false
+	}
+
+	switch e.op {
+	case "==":
+		return v == e.str
+	case "~=":
+		return v != e.str
+	}
+	return false
+}
+
+func (v *exprVar) value(context *Context) string {
+	switch v.kind {
+	case varArg:
+		return context.GetQueries().Get(v.name)
+	case varHTTP:
+		return context.GetHeaders().Get(v.name)
+	case varCookie:
+		if c, err := context.request.Cookie(v.name); err == nil {
+			return c.Value
+		}
+		return ""
+	case varURI:
+		return context.path
+	case varHost:
+		host := context.request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return host
+	case varMethod:
+		return context.request.Method
+	case varRemoteAddr:
+		addr := context.request.RemoteAddr
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			addr = h
+		}
+		return addr
+	}
+	return ""
+}
+
+// values returns all the values of multi-valued variables, it's used by the has operator.
+func (v *exprVar) values(context *Context) []string {
+	switch v.kind {
+	case varArg:
+		return context.GetQueries()[v.name]
+	case varHTTP:
+		return context.GetHeaders().Values(v.name)
+	}
+	if s := v.value(context); s != "" {
+		return []string{s}
+	}
+	return nil
+}
+
+// present reports whether the variable is in the request, uri, host,
+// request_method and remote_addr always are.
+func (v *exprVar) present(context *Context) bool {
+	switch v.kind {
+	case varArg:
+		_, ok := context.GetQueries()[v.name]
+		return ok
+	case varHTTP:
+		return len(context.GetHeaders().Values(v.name)) > 0
+	case varCookie:
+		_, err := context.request.Cookie(v.name)
+		return err == nil
+	}
+	return true
+}
+
+func parseExprVar(name string) (exprVar, error) {
+	v, err := parseExprVarKind(name)
+	v.source = name
+	return v, err
+}
+
+func parseExprVarKind(name string) (exprVar, error) {
+	switch {
+	case strings.HasPrefix(name, "arg_") && len(name) > 4:
+		return exprVar{kind: varArg, name: name[4:]}, nil
+	case strings.HasPrefix(name, "http_") && len(name) > 5:
+		key := http.CanonicalHeaderKey(strings.ReplaceAll(name[5:], "_", "-"))
+		return exprVar{kind: varHTTP, name: key}, nil
+	case strings.HasPrefix(name, "cookie_") && len(name) > 7:
+		return exprVar{kind: varCookie, name: name[7:]}, nil
+	case name == "uri":
+		return exprVar{kind: varURI}, nil
+	case name == "host":
+		return exprVar{kind: varHost}, nil
+	case name == "request_method":
+		return exprVar{kind: varMethod}, nil
+	case name == "remote_addr":
+		return exprVar{kind: varRemoteAddr}, nil
+	}
+	return exprVar{}, fmt.Errorf("unknown variable '%s'", name)
+}
+
+// compileVars compiles the vars of a path into an expression, a nil expression
+// is returned for empty vars.
+func compileVars(vars []interface{}) (expr, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	return compileExprList(vars)
+}
+
+func compileExprList(list []interface{}) (expr, error) {
+	res := make(exprAnd, 0, len(list))
+	for i, item := range list {
+		sub, ok := item.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expression #%d: expected a list, got %v", i, item)
+		}
+		x, err := compileExpr(sub)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, x)
+	}
+	if len(res) == 1 {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func compileExpr(list []interface{}) (expr, error) {
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	head, ok := list[0].(string)
+	if !ok {
+		// a list of expressions
+		return compileExprList(list)
+	}
+
+	switch head {
+	case "AND", "OR", "!AND", "!OR", "NOT":
+		if len(list) < 2 {
+			return nil, fmt.Errorf("%s: missing sub expressions", head)
+		}
+		if head == "NOT" && len(list) != 2 {
+			return nil, fmt.Errorf("NOT: expects exactly one sub expression")
+		}
+
+		subs := make([]expr, 0, len(list)-1)
+		for _, item := range list[1:] {
+			sub, ok := item.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a list, got %v", head, item)
+			}
+			x, err := compileExpr(sub)
+			if err != nil {
+				return nil, err
+			}
+			subs = append(subs, x)
+		}
+
+		switch head {
+		case "AND":
+			return exprAnd(subs), nil
+		case "OR":
+			return exprOr(subs), nil
+		case "!AND":
+			return &exprNot{x: exprAnd(subs)}, nil
+		case "!OR":
+			return &exprNot{x: exprOr(subs)}, nil
+		default:
+			return &exprNot{x: subs[0]}, nil
+		}
+	}
+
+	return compileCmp(head, list[1:])
+}
+
+func compileCmp(name string, args []interface{}) (expr, error) {
+	variable, err := parseExprVar(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := &exprCmp{variable: variable}
+
+	if len(args) == 3 {
+		if s, ok := args[0].(string); ok && s == "!" {
+			cmp.negate = true
+			args = args[1:]
+		}
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s: expected [var, op, value] or [var, \"!\", op, value]", name)
+	}
+
+	op, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid operator %v", name, args[0])
+	}
+	cmp.op = op
+
+	value := args[1]
+	src, _ := json.Marshal(value)
+	cmp.src = fmt.Sprintf("%s %s %s", name, op, src)
+	if cmp.negate {
+		cmp.src = fmt.Sprintf("%s ! %s %s", name, op, src)
+	}
+
+	switch op {
+	case "==", "~=", ">", ">=", "<", "<=":
+		str, isStr := value.(string)
+		n, isNum := toFloat(value)
+		if isStr && op != "==" && op != "~=" {
+			// like tonumber in lua-resty-expr
+			n, isNum = parseNumber(str)
+		}
+		switch {
+		case isNum:
+			cmp.num, cmp.isNum = n, true
+		case isStr && (op == "==" || op == "~="):
+			cmp.str = str
+		default:
+			return nil, fmt.Errorf("%s %s: invalid value %v", name, op, value)
+		}
+
+	case "~~", "~*":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s %s: regexp must be a string", name, op)
+		}
+		if op == "~*" {
+			s = "(?i)" + s
+		}
+		cmp.re, err = regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", name, op, err)
+		}
+
+	case "in":
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s in: value must be a list", name)
+		}
+		for _, item := range items {
+			cmp.set = append(cmp.set, toString(item))
+		}
+
+	case "has":
+		cmp.str = toString(value)
+
+	case "present":
+		present, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s present: value must be a boolean", name)
+		}
+		if !present {
+			cmp.negate = !cmp.negate
+		}
+
+	case "ipmatch":
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			n, err := parseIPNet(toString(item))
+			if err != nil {
+				return nil, fmt.Errorf("%s ipmatch: %v", name, err)
+			}
+			cmp.nets = append(cmp.nets, n)
+		}
+
+	default:
+		return nil, fmt.Errorf("%s: unknown operator '%s'", name, op)
+	}
+
+	return cmp, nil
+}
+
+// parseIPNet parses a CIDR, a single IP is treated as a network of one address.
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.IndexByte(s, '/') >= 0 {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address '%s'", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// parseNumber parses a decimal number, the hex and underscore forms, NaN and
+// the infinities that strconv.ParseFloat also takes are rejected.
+func parseNumber(s string) (float64, bool) {
+	if strings.ContainsAny(s, "_xX") {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVars(t *testing.T) {
+	var orVars []interface{}
+	err := json.Unmarshal([]byte(`[
+		["OR", ["http_x_env", "==", "a"], ["arg_y", "~~", "^b[0-9]+$"]],
+		["NOT", ["cookie_z", "==", "1"]]
+	]`), &orVars)
+	assert.Nil(t, err)
+
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{
+					Path:    "/expr",
+					Backend: "or",
+					Vars:    orVars,
+				},
+
+				{
+					Path:    "/expr",
+					Backend: "num",
+					Vars: []interface{}{
+						[]interface{}{"arg_n", ">", 10},
+						[]interface{}{"arg_n", "<=", "20"},
+					},
+				},
+
+				{
+					Path:    "/expr",
+					Backend: "in",
+					Vars: []interface{}{
+						[]interface{}{"request_method", "in", []interface{}{"PUT", "PATCH"}},
+						[]interface{}{"arg_tag", "has", "beta"},
+						[]interface{}{"http_user_agent", "!", "~*", "bot"},
+					},
+				},
+
+				{
+					Path:    "/expr",
+					Backend: "present",
+					Vars: []interface{}{
+						[]interface{}{"arg_flag", "present", true},
+						[]interface{}{"http_x_debug", "present", false},
+					},
+				},
+
+				{
+					Path:    "/expr",
+					Backend: "fallback",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		m       string
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{m: "GET", r: "/expr", h: "fallback"},
+		{m: "GET", r: "/expr", h: "or", headers: map[string]string{"X-Env": "a"}},
+		{m: "GET", r: "/expr?y=b12", h: "or"},
+		{m: "GET", r: "/expr?y=b12", h: "fallback", headers: map[string]string{"Cookie": "z=1"}},
+		{m: "GET", r: "/expr?y=b12", h: "or", headers: map[string]string{"Cookie": "z=2"}},
+		{m: "GET", r: "/expr?n=15", h: "num"},
+		{m: "GET", r: "/expr?n=20", h: "num"},
+		{m: "GET", r: "/expr?n=25", h: "fallback"},
+		{m: "GET", r: "/expr?n=abc", h: "fallback"},
+		{m: "GET", r: "/expr?n=1.5e1", h: "num"},
+		{m: "GET", r: "/expr?n=0x10", h: "fallback"},
+		{m: "GET", r: "/expr?n=0x1p4", h: "fallback"},
+		{m: "GET", r: "/expr?n=1_5", h: "fallback"},
+		{m: "PUT", r: "/expr?tag=alpha&tag=beta", h: "in"},
+		{m: "PUT", r: "/expr?tag=alpha&tag=beta", h: "fallback", headers: map[string]string{"User-Agent": "GoogleBot"}},
+		{m: "POST", r: "/expr?tag=beta", h: "fallback"},
+		{m: "GET", r: "/expr?flag", h: "present"},
+		{m: "GET", r: "/expr?flag=", h: "fallback", headers: map[string]string{"X-Debug": ""}},
+	}
+
+	router := New(rules, false)
+	assert := assert.New(t)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(tt.h, backend, "%s %s %v", tt.m, tt.r, tt.headers)
+	}
+}
+
+func TestCompileVarsError(t *testing.T) {
+	tests := [][]interface{}{
+		{"arg_x", "==", "y"},
+		{[]interface{}{"foo", "==", "y"}},
+		{[]interface{}{"arg_x", "=", "y"}},
+		{[]interface{}{"arg_x", "~~", "("}},
+		{[]interface{}{"arg_x", ">", "y"}},
+		{[]interface{}{"arg_x", "<", "NaN"}},
+		{[]interface{}{"arg_x", "<", "Inf"}},
+		{[]interface{}{"arg_x", "<", "0x10"}},
+		{[]interface{}{"arg_x", "<", "0x1p4"}},
+		{[]interface{}{"arg_x", "<", "1_000"}},
+		{[]interface{}{"arg_x", "present", "yes"}},
+		{[]interface{}{"arg_x", "in", "y"}},
+		{[]interface{}{"NOT", []interface{}{"arg_x", "==", "y"}, []interface{}{"arg_x", "==", "z"}}},
+		{[]interface{}{"OR"}},
+	}
+
+	for _, vars := range tests {
+		_, err := compileVars(vars)
+		assert.NotNil(t, err, "%v", vars)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		s  string
+		n  float64
+		ok bool
+	}{
+		{s: "20", n: 20, ok: true},
+		{s: "-1.5", n: -1.5, ok: true},
+		{s: "1e3", n: 1000, ok: true},
+		{s: ".5", n: 0.5, ok: true},
+		{s: ""},
+		{s: "abc"},
+		{s: "0x10"},
+		{s: "0X1p4"},
+		{s: "1_000"},
+		{s: "Inf"},
+		{s: "-infinity"},
+		{s: "NaN"},
+		{s: "1e400"},
+	}
+
+	for _, tt := range tests {
+		n, ok := parseNumber(tt.s)
+		assert.Equal(t, tt.ok, ok, tt.s)
+		assert.Equal(t, tt.n, n, tt.s)
+	}
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// ImportWarning reports a part of an imported route definition that cannot be
+// represented by the router, the importers never drop such parts silently.
+type ImportWarning struct {
+	// Route identifies the route in the source document, e.g. its id or index
+	Route string
+	// Field is the name of the field in the source document
+	Field string
+	// Reason explains why the field isn't represented
+	Reason string
+}
+
+func (w ImportWarning) String() string {
+	return fmt.Sprintf("route %s: field %s: %s", w.Route, w.Field, w.Reason)
+}
+
+// rawFields decodes a JSON object into its fields, to look for unknown ones.
+func rawFields(data []byte) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// unknownFields returns the sorted names of the fields in none of the known lists.
+func unknownFields(fields map[string]json.RawMessage, known ...[]string) []string {
+	names := []string{}
+	for name := range fields {
+		found := false
+		for _, k := range known {
+			if StrInSlice(name, k) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPath reports whether New accepts the path, it panics on invalid paths.
+// A copy of the path is checked as New compiles the regexps of the headers.
+func checkPath(path *Path) (err error) {
+	data, err := json.Marshal(path)
+	if err != nil {
+		return err
+	}
+	cp := &Path{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	New([]*Rule{{Paths: []*Path{cp}}}, false)
+	return nil
+}
+
+// hostsOverlap reports whether a host may match both rules, a rule without
+// host matches any host.
+func hostsOverlap(a, b *Rule) bool {
+	ma, erra := importedHost(a)
+	mb, errb := importedHost(b)
+	if erra != nil || errb != nil {
+		return true
+	}
+	if ma.host == "" && ma.hostRE == nil || mb.host == "" && mb.hostRE == nil {
+		return true
+	}
+	_, ok := overlappingHost(ma, mb)
+	return ok
+}
+
+func importedHost(rule *Rule) (*muxRule, error) {
+	mr := &muxRule{host: rule.Host, hostRegexp: rule.HostRegexp}
+	if rule.HostRegexp != "" {
+		re, err := regexp.Compile(rule.HostRegexp)
+		if err != nil {
+			return nil, err
+		}
+		mr.hostRE = re
+	}
+	return mr, nil
+}
+
+// matchesEverything reports whether one of the paths matches every request, so
+// that no request for the host falls through to the later rules.
+func matchesEverything(paths []*Path) bool {
+	for _, p := range paths {
+		all := p.PathType == PathTypePrefix && p.Path == "/" ||
+			(p.PathType == "" || p.PathType == PathTypeImplementationSpecific) && p.Path == "/*"
+		if all && len(p.Methods) == 0 && len(p.Headers) == 0 && len(p.Queries) == 0 && len(p.Vars) == 0 &&
+			len(p.Matchers) == 0 && len(p.Consumes) == 0 && len(p.Produces) == 0 && len(p.Languages) == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,545 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	k8sObject struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+		Metadata   struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Spec  yaml.Node   `yaml:"spec"`
+		Items []yaml.Node `yaml:"items"`
+	}
+
+	ingressSpec struct {
+		DefaultBackend *ingressBackend `yaml:"defaultBackend"`
+		Rules          []struct {
+			Host string `yaml:"host"`
+			HTTP *struct {
+				Paths []struct {
+					Path     string         `yaml:"path"`
+					PathType string         `yaml:"pathType"`
+					Backend  ingressBackend `yaml:"backend"`
+				} `yaml:"paths"`
+			} `yaml:"http"`
+		} `yaml:"rules"`
+	}
+
+	ingressBackend struct {
+		Service *struct {
+			Name string `yaml:"name"`
+			Port struct {
+				Number int    `yaml:"number"`
+				Name   string `yaml:"name"`
+			} `yaml:"port"`
+		} `yaml:"service"`
+		Resource *struct {
+			Kind string `yaml:"kind"`
+			Name string `yaml:"name"`
+		} `yaml:"resource"`
+	}
+
+	httpRouteSpec struct {
+		Hostnames []string `yaml:"hostnames"`
+		Rules     []struct {
+			Matches []httpRouteMatch `yaml:"matches"`
+			Filters []struct {
+				Type string `yaml:"type"`
+				// the config of the filter, which is left out anyway
+				Config map[string]interface{} `yaml:",inline"`
+			} `yaml:"filters"`
+			BackendRefs []struct {
+				Name   string `yaml:"name"`
+				Port   int    `yaml:"port"`
+				Weight *int   `yaml:"weight"`
+			} `yaml:"backendRefs"`
+		} `yaml:"rules"`
+	}
+
+	httpRouteMatch struct {
+		Path *struct {
+			Type  string `yaml:"type"`
+			Value string `yaml:"value"`
+		} `yaml:"path"`
+		Headers     []httpRouteValueMatch `yaml:"headers"`
+		QueryParams []httpRouteValueMatch `yaml:"queryParams"`
+		Method      string                `yaml:"method"`
+	}
+
+	httpRouteValueMatch struct {
+		Type  string `yaml:"type"`
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	}
+
+	// k8sImporter collects the paths of all the manifests by host, the rules of
+	// several objects for the same host are merged.
+	k8sImporter struct {
+		rules    map[string]*Rule
+		order    []string
+		warnings []ImportWarning
+	}
+)
+
+// k8sIgnoredFields are spec fields selecting the controller, without influence
+// on routing.
+var k8sIgnoredFields = []string{"ingressClassName", "parentRefs"}
+
+// ImportKubernetes builds rules from networking.k8s.io/v1 Ingress and
+// gateway.networking.k8s.io HTTPRoute objects, data holds YAML manifests, one
+// or more documents each with an object or a List of them.
+//
+// The rules of exact hosts come first, then those of wildcard hosts, the longer
+// ones first, and finally the paths without host. A request matching no path
+// of its host falls through to the less specific hosts, this is reported as a
+// warning unless a path of the host matches everything. An Ingress wildcard host
+// matches a single DNS label, an HTTPRoute one matches one or more. The backend
+// is the service name and port, e.g. svc:80, the heaviest of several backend
+// refs is used.
+//
+// Paths are ordered by the precedence rules of the Gateway API, the tree
+// prefers exact paths and longer prefixes, then for the same path the method
+// matches come first, followed by the matches with more headers and more query
+// params. Objects and rules otherwise keep the order of the manifests, which
+// stands in for the creation timestamp. Anything that cannot be represented,
+// like filters or the spec fields unknown to the importer, is reported as a
+// warning.
+func ImportKubernetes(data []byte) ([]*Rule, []ImportWarning, error) {
+	imp := &k8sImporter{rules: map[string]*Rule{}}
+	if err := imp.load(data); err != nil {
+		return nil, nil, err
+	}
+	return imp.result()
+}
+
+// ImportKubernetesFiles is like ImportKubernetes for the manifests in files.
+func ImportKubernetesFiles(filenames ...string) ([]*Rule, []ImportWarning, error) {
+	imp := &k8sImporter{rules: map[string]*Rule{}}
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := imp.load(data); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return imp.result()
+}
+
+func (imp *k8sImporter) load(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := imp.addObject(&node); err != nil {
+			return err
+		}
+	}
+}
+
+func (imp *k8sImporter) addObject(node *yaml.Node) error {
+	obj := &k8sObject{}
+	if err := node.Decode(obj); err != nil {
+		return err
+	}
+
+	id := obj.Kind + "/" + obj.Metadata.Name
+	if obj.Metadata.Namespace != "" {
+		id = obj.Kind + "/" + obj.Metadata.Namespace + "/" + obj.Metadata.Name
+	}
+	warn := func(field, reason string) {
+		imp.warnings = append(imp.warnings, ImportWarning{Route: id, Field: field, Reason: reason})
+	}
+
+	group := obj.APIVersion
+	if i := strings.IndexByte(group, '/'); i >= 0 {
+		group = group[:i]
+	}
+
+	switch {
+	case obj.Kind == "":
+		// an empty document
+		return nil
+
+	case strings.HasSuffix(obj.Kind, "List"):
+		for i := range obj.Items {
+			if err := imp.addObject(&obj.Items[i]); err != nil {
+				return err
+			}
+		}
+
+	case obj.Kind == "Ingress" && obj.APIVersion == "networking.k8s.io/v1":
+		spec := &ingressSpec{}
+		if err := obj.Spec.Decode(spec); err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+		warnUnknownYAMLFields(&obj.Spec, spec, warn)
+		imp.addIngress(spec, warn)
+
+	case obj.Kind == "HTTPRoute" && group == "gateway.networking.k8s.io":
+		spec := &httpRouteSpec{}
+		if err := obj.Spec.Decode(spec); err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+		warnUnknownYAMLFields(&obj.Spec, spec, warn)
+		imp.addHTTPRoute(spec, obj.Metadata.Name, warn)
+
+	default:
+		warn("kind", fmt.Sprintf("%s %s is not supported, the object is left out", obj.APIVersion, obj.Kind))
+	}
+
+	return nil
+}
+
+// warnUnknownYAMLFields reports the fields of the spec node the spec struct
+// doesn't decode.
+func warnUnknownYAMLFields(node *yaml.Node, spec interface{}, warn func(field, reason string)) {
+	for _, name := range unknownYAMLFields(node, reflect.TypeOf(spec), "") {
+		if !StrInSlice(name, k8sIgnoredFields) {
+			warn(name, "not supported by the router")
+		}
+	}
+}
+
+// unknownYAMLFields returns the paths of the keys of the mapping nodes without
+// a yaml field in the type, e.g. rules[0].http.foo.
+func unknownYAMLFields(node *yaml.Node, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	unknown := []string{}
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct && t != reflect.TypeOf(yaml.Node{}):
+		fields := map[string]reflect.Type{}
+		inline := false
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("yaml"), ",")
+			switch {
+			case len(tag) > 1 && tag[1] == "inline":
+				inline = true
+			case tag[0] != "":
+				fields[tag[0]] = f.Type
+			default:
+				fields[strings.ToLower(f.Name)] = f.Type
+			}
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			name := key
+			if path != "" {
+				name = path + "." + key
+			}
+			if ft, ok := fields[key]; ok {
+				unknown = append(unknown, unknownYAMLFields(node.Content[i+1], ft, name)...)
+			} else if !inline {
+				unknown = append(unknown, name)
+			}
+		}
+
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			unknown = append(unknown, unknownYAMLFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return unknown
+}
+
+func (imp *k8sImporter) addIngress(spec *ingressSpec, warn func(field, reason string)) {
+	for _, rule := range spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		host, hostRegexp, ok := k8sHost(rule.Host, "[^.]+")
+		if !ok {
+			warn("host", fmt.Sprintf("invalid host %s, the rule is left out", rule.Host))
+			continue
+		}
+
+		for _, p := range rule.HTTP.Paths {
+			path := &Path{Path: p.Path, PathType: p.PathType, Backend: ingressBackendName(&p.Backend, warn)}
+			switch p.PathType {
+			case PathTypeExact, PathTypePrefix:
+				if !strings.HasPrefix(p.Path, "/") || strings.ContainsAny(p.Path, "{}*[]") {
+					warn("path", fmt.Sprintf("invalid path %s, the path is left out", p.Path))
+					continue
+				}
+			case PathTypeImplementationSpecific:
+				// the pattern syntax of the router
+				if p.Path == "" {
+					path.Path = "/*"
+				}
+			default:
+				warn("pathType", fmt.Sprintf("invalid pathType %s, the path is left out", p.PathType))
+				continue
+			}
+			r := imp.rule(host, hostRegexp)
+			r.Paths = append(r.Paths, path)
+		}
+	}
+
+	if spec.DefaultBackend != nil {
+		path := &Path{Path: "/", PathType: PathTypePrefix, Backend: ingressBackendName(spec.DefaultBackend, warn)}
+		r := imp.rule("", "")
+		r.Paths = append(r.Paths, path)
+	}
+}
+
+func ingressBackendName(b *ingressBackend, warn func(field, reason string)) string {
+	switch {
+	case b.Service != nil && b.Service.Port.Number != 0:
+		return b.Service.Name + ":" + strconv.Itoa(b.Service.Port.Number)
+	case b.Service != nil && b.Service.Port.Name != "":
+		return b.Service.Name + ":" + b.Service.Port.Name
+	case b.Service != nil:
+		return b.Service.Name
+	case b.Resource != nil:
+		warn("backend.resource", "resource backends are not supported, the resource name is used as backend")
+		return b.Resource.Name
+	}
+	return ""
+}
+
+func (imp *k8sImporter) addHTTPRoute(spec *httpRouteSpec, name string, warn func(field, reason string)) {
+	type hostKey struct{ host, regexp string }
+
+	hosts := []hostKey{}
+	for _, hostname := range spec.Hostnames {
+		host, hostRegexp, ok := k8sHost(hostname, ".+")
+		if !ok {
+			warn("hostnames", fmt.Sprintf("invalid hostname %s, it's left out", hostname))
+			continue
+		}
+		hosts = append(hosts, hostKey{host, hostRegexp})
+	}
+	if len(spec.Hostnames) == 0 {
+		hosts = append(hosts, hostKey{})
+	}
+
+	for i, rule := range spec.Rules {
+		for _, f := range rule.Filters {
+			warn(fmt.Sprintf("rules[%d].filters", i), fmt.Sprintf("filter %s is not supported by the router", f.Type))
+		}
+
+		backend := name
+		weight := -1
+		for _, ref := range rule.BackendRefs {
+			w := 1
+			if ref.Weight != nil {
+				w = *ref.Weight
+			}
+			if w > weight {
+				backend, weight = ref.Name, w
+				if ref.Port != 0 {
+					backend += ":" + strconv.Itoa(ref.Port)
+				}
+			}
+		}
+		switch {
+		case len(rule.BackendRefs) == 0:
+			warn(fmt.Sprintf("rules[%d].backendRefs", i), "missing backend refs, the route name is used as backend")
+		case len(rule.BackendRefs) > 1:
+			warn(fmt.Sprintf("rules[%d].backendRefs", i), "traffic splitting is not supported, the heaviest backend is used")
+		}
+
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []httpRouteMatch{{}}
+		}
+
+		for _, m := range matches {
+			path, err := httpRoutePath(&m, backend)
+			if err != nil {
+				warn(fmt.Sprintf("rules[%d].matches", i), err.Error()+", the match is left out")
+				continue
+			}
+			for _, h := range hosts {
+				r := imp.rule(h.host, h.regexp)
+				r.Paths = append(r.Paths, path)
+			}
+		}
+	}
+}
+
+func httpRoutePath(m *httpRouteMatch, backend string) (*Path, error) {
+	path := &Path{Path: "/", PathType: PathTypePrefix, Backend: backend}
+
+	if m.Path != nil {
+		if m.Path.Value != "" {
+			path.Path = m.Path.Value
+		}
+		switch m.Path.Type {
+		case "", "PathPrefix":
+		case "Exact":
+			path.PathType = PathTypeExact
+		case "RegularExpression":
+			path.PathType = PathTypeRegularExpression
+		default:
+			return nil, fmt.Errorf("unsupported path type %s", m.Path.Type)
+		}
+	}
+
+	switch path.PathType {
+	case PathTypeRegularExpression:
+		if _, err := regexp.Compile(path.Path); err != nil {
+			return nil, fmt.Errorf("invalid path regexp: %v", err)
+		}
+	default:
+		if !strings.HasPrefix(path.Path, "/") || strings.ContainsAny(path.Path, "{}*[]") {
+			return nil, fmt.Errorf("invalid path %s", path.Path)
+		}
+	}
+
+	if m.Method != "" {
+		if _, ok := methodMap[m.Method]; !ok {
+			return nil, fmt.Errorf("unsupported method %s", m.Method)
+		}
+		path.Methods = []string{m.Method}
+	}
+
+	for _, h := range m.Headers {
+		header := &Header{Key: h.Name}
+		if err := httpRouteValue(h, &header.Values, &header.Regexp); err != nil {
+			return nil, err
+		}
+		path.Headers = append(path.Headers, header)
+		path.MatchAllHeader = true
+	}
+
+	for _, q := range m.QueryParams {
+		query := &Query{Key: q.Name}
+		if err := httpRouteValue(q, &query.Values, &query.Regexp); err != nil {
+			return nil, err
+		}
+		path.Queries = append(path.Queries, query)
+	}
+
+	return path, nil
+}
+
+// httpRouteValue sets the values or the regexp of a header or query param.
+func httpRouteValue(m httpRouteValueMatch, values *[]string, re *string) error {
+	switch m.Type {
+	case "", "Exact":
+		*values = []string{m.Value}
+	case "RegularExpression":
+		*re = "^(?:" + m.Value + ")$"
+		if _, err := regexp.Compile(*re); err != nil {
+			return fmt.Errorf("invalid regexp for %s: %v", m.Name, err)
+		}
+	default:
+		return fmt.Errorf("unsupported match type %s for %s", m.Type, m.Name)
+	}
+	return nil
+}
+
+// k8sHost translates a host, a leading *. is replaced by label, the regexp of
+// the labels the wildcard matches.
+func k8sHost(host, label string) (string, string, bool) {
+	host = strings.ToLower(host)
+	switch {
+	case host == "":
+		return "", "", true
+	case strings.HasPrefix(host, "*.") && !strings.Contains(host[2:], "*"):
+		return "", "^" + label + regexp.QuoteMeta(host[1:]) + "$", true
+	case strings.Contains(host, "*"):
+		return "", "", false
+	}
+	return host, "", true
+}
+
+// rule returns the rule of the host, creating it if needed.
+func (imp *k8sImporter) rule(host, hostRegexp string) *Rule {
+	key := host + " " + hostRegexp
+	r, ok := imp.rules[key]
+	if !ok {
+		r = &Rule{Host: host, HostRegexp: hostRegexp}
+		imp.rules[key] = r
+		imp.order = append(imp.order, key)
+	}
+	return r
+}
+
+func (imp *k8sImporter) result() ([]*Rule, []ImportWarning, error) {
+	rules := make([]*Rule, 0, len(imp.order))
+	for _, key := range imp.order {
+		rules = append(rules, imp.rules[key])
+	}
+
+	hostRank := func(r *Rule) int {
+		switch {
+		case r.Host != "":
+			return 0
+		case r.HostRegexp != "":
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		ri, rj := hostRank(rules[i]), hostRank(rules[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return len(rules[i].HostRegexp) > len(rules[j].HostRegexp)
+	})
+
+	for _, r := range rules {
+		sort.SliceStable(r.Paths, func(i, j int) bool {
+			pi, pj := r.Paths[i], r.Paths[j]
+			if (len(pi.Methods) > 0) != (len(pj.Methods) > 0) {
+				return len(pi.Methods) > 0
+			}
+			if len(pi.Headers) != len(pj.Headers) {
+				return len(pi.Headers) > len(pj.Headers)
+			}
+			return len(pi.Queries) > len(pj.Queries)
+		})
+	}
+
+	for i, r := range rules {
+		if matchesEverything(r.Paths) {
+			continue
+		}
+		for _, later := range rules[i+1:] {
+			if hostsOverlap(r, later) {
+				imp.warnings = append(imp.warnings, ImportWarning{Route: describeHost(r), Field: "host", Reason: fmt.Sprintf(
+					"a request matching no path falls through to the rule of %s", describeHost(later))})
+				break
+			}
+		}
+	}
+
+	if imp.warnings == nil {
+		imp.warnings = []ImportWarning{}
+	}
+	return rules, imp.warnings, nil
+}
@@ -0,0 +1,197 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testIngress = `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: shop
+  namespace: prod
+spec:
+  ingressClassName: nginx
+  tls:
+  - hosts: [shop.example.com]
+    secretName: shop-tls
+  defaultBackend:
+    service:
+      name: fallback
+      port:
+        number: 80
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /cart
+        pathType: Prefix
+        backend:
+          service:
+            name: cart
+            port:
+              name: http
+      - path: /cart/checkout
+        pathType: Exact
+        priority: 1
+        backend:
+          service:
+            name: checkout
+            port:
+              number: 8080
+      - path: /items/{id:int}
+        pathType: ImplementationSpecific
+        backend:
+          resource:
+            kind: Bucket
+            name: items
+  - host: "*.example.com"
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: wildcard
+            port:
+              number: 80
+`
+
+const testHTTPRoute = `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: api
+spec:
+  parentRefs:
+  - name: gateway
+  hostnames: ["api.example.com", "*.api.example.com"]
+  rules:
+  - backendRefs:
+    - name: api
+      port: 80
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /users
+      method: POST
+    backendRefs:
+    - name: users-write
+      port: 80
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /users
+      headers:
+      - name: X-Version
+        value: "2"
+      queryParams:
+      - type: RegularExpression
+        name: page
+        value: "[0-9]+"
+    filters:
+    - type: RequestHeaderModifier
+      requestHeaderModifier:
+        add:
+        - name: X-Split
+          value: "yes"
+    backendRefs:
+    - name: users-v1
+      port: 80
+      weight: 10
+    - name: users-v2
+      port: 80
+      weight: 90
+  - matches:
+    - path:
+        type: Exact
+        value: /users/me
+    - path:
+        type: RegularExpression
+        value: /users/[0-9]+/avatar
+    backendRefs:
+    - name: profile
+      port: 80
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: api
+`
+
+func TestImportKubernetes(t *testing.T) {
+	dir := t.TempDir()
+	ingress := filepath.Join(dir, "ingress.yaml")
+	route := filepath.Join(dir, "route.yaml")
+	assert.Nil(t, os.WriteFile(ingress, []byte(testIngress), 0o644))
+	assert.Nil(t, os.WriteFile(route, []byte(testHTTPRoute), 0o644))
+
+	rules, warnings, err := ImportKubernetesFiles(ingress, route)
+	assert.Nil(t, err)
+	assert.Len(t, rules, 5)
+	assert.Equal(t, "shop.example.com", rules[0].Host)
+	assert.Equal(t, "api.example.com", rules[1].Host)
+	assert.Equal(t, `^.+\.api\.example\.com$`, rules[2].HostRegexp)
+	assert.Equal(t, `^[^.]+\.example\.com$`, rules[3].HostRegexp)
+	assert.Equal(t, "", rules[4].Host+rules[4].HostRegexp)
+	assert.Equal(t, []ImportWarning{
+		{Route: "Ingress/prod/shop", Field: "tls", Reason: "not supported by the router"},
+		{Route: "Ingress/prod/shop", Field: "rules[0].http.paths[1].priority", Reason: "not supported by the router"},
+		{Route: "Ingress/prod/shop", Field: "backend.resource", Reason: "resource backends are not supported, the resource name is used as backend"},
+		{Route: "HTTPRoute/api", Field: "rules[2].filters", Reason: "filter RequestHeaderModifier is not supported by the router"},
+		{Route: "HTTPRoute/api", Field: "rules[2].backendRefs", Reason: "traffic splitting is not supported, the heaviest backend is used"},
+		{Route: "Service/api", Field: "kind", Reason: "v1 Service is not supported, the object is left out"},
+		{Route: "host shop.example.com", Field: "host", Reason: `a request matching no path falls through to the rule of host ~ ^[^.]+\.example\.com$`},
+	}, warnings)
+
+	tests := []struct {
+		m       string
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{m: "GET", r: "http://shop.example.com/cart", h: "cart:http"},
+		{m: "GET", r: "http://shop.example.com/cart/checkout", h: "checkout:8080"},
+		{m: "GET", r: "http://shop.example.com/cart/checkout/x", h: "cart:http"},
+		{m: "GET", r: "http://shop.example.com/items/1", h: "items"},
+		{m: "GET", r: "http://shop.example.com/other", h: "wildcard:80"},
+		{m: "GET", r: "http://www.example.com/other", h: "wildcard:80"},
+		{m: "GET", r: "http://a.b.example.com/other", h: "fallback:80"},
+		{m: "GET", r: "http://api.example.com/", h: "api:80"},
+		{m: "POST", r: "http://api.example.com/users", h: "users-write:80", headers: map[string]string{"X-Version": "2"}},
+		{m: "GET", r: "http://api.example.com/users?page=1", h: "users-v2:80", headers: map[string]string{"X-Version": "2"}},
+		{m: "GET", r: "http://api.example.com/users?page=x", h: "api:80", headers: map[string]string{"X-Version": "2"}},
+		{m: "GET", r: "http://a.b.api.example.com/users/me", h: "profile:80"},
+		{m: "GET", r: "http://api.example.com/users/12/avatar", h: "profile:80"},
+		{m: "GET", r: "http://api.example.com/users/x/avatar", h: "api:80"},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s %v", tt.m, tt.r, tt.headers)
+	}
+
+	_, _, err = ImportKubernetes([]byte("kind: [Ingress"))
+	assert.NotNil(t, err)
+}
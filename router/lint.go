@@ -0,0 +1,395 @@
+package router
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// The kinds of lint issues.
+const (
+	// LintDuplicate is a route declaring the same methods, pattern and
+	// predicates as an earlier route, only the first one is ever matched
+	LintDuplicate = "duplicate"
+	// LintShadowed is a route never matched as an earlier route of the same
+	// node takes all its methods without extra predicates
+	LintShadowed = "shadowed"
+	// LintAmbiguousParams is a route matching the same paths as an earlier
+	// route with differently named params, e.g. /a/{id} and /a/{name}
+	LintAmbiguousParams = "ambiguous-params"
+	// LintUnreachable is a route declared before a static route taking all its
+	// requests on one of its paths, the static routes are matched before the
+	// params whatever the declaration order
+	LintUnreachable = "unreachable"
+	// LintHostOverlap is a rule whose hosts are also matched by an earlier
+	// rule, which is tried first
+	LintHostOverlap = "host-overlap"
+)
+
+// maxRegexpSamples bounds the samples generated from a host regexp.
+const maxRegexpSamples = 32
+
+// LintIssue reports a route or a rule that doesn't behave as declared.
+type LintIssue struct {
+	Kind string
+	// Host is the host or the host regexp of the rule, empty for any host
+	Host string
+	// Route is the pattern of the route, empty for LintHostOverlap
+	Route   string
+	Backend string
+	// Message explains the issue and names the route or rule at fault
+	Message string
+}
+
+func (i LintIssue) String() string {
+	host := i.Host
+	if host == "" {
+		host = "*"
+	}
+	if i.Route == "" {
+		return fmt.Sprintf("%s: host %s: %s", i.Kind, host, i.Message)
+	}
+	return fmt.Sprintf("%s: host %s: route %s -> %s: %s", i.Kind, host, i.Route, i.Backend, i.Message)
+}
+
+// Lint analyzes the rules as New builds them and reports the duplicate,
+// shadowed, ambiguous and unreachable routes and the overlapping hosts, it
+// returns an error if New rejects the rules.
+//
+// The overlaps of host regexps are found by matching samples of the later
+// regexp against the earlier rules, some overlaps may be missed.
+func Lint(rules []*Rule, disablePathCache bool) ([]LintIssue, error) {
+	ar, err := buildRouter(rules, disablePathCache)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []LintIssue{}
+	for i, mr := range ar.rules {
+		issues = append(issues, lintHosts(ar.rules[:i], mr)...)
+		issues = append(issues, lintRoutes(mr)...)
+	}
+	return issues, nil
+}
+
+func (mr *muxRule) describeHost() string {
+	if mr.host != "" {
+		return mr.host
+	}
+	return mr.hostRegexp
+}
+
+// lintHosts reports the earlier rules matching the hosts of the rule, the
+// hosts of two rules overlap if a sample host of either one matches the other.
+func lintHosts(earlier []*muxRule, mr *muxRule) []LintIssue {
+	issues := []LintIssue{}
+	for _, e := range earlier {
+		msg := ""
+		switch {
+		case e.host == "" && e.hostRE == nil:
+			msg = "an earlier rule for any host is tried first"
+		case mr.host == "" && mr.hostRE == nil:
+			// the rule for any host is the fallback of the earlier rules
+		default:
+			if host, ok := overlappingHost(e, mr); ok {
+				msg = fmt.Sprintf("host %s is also matched by the earlier rule for %s, which is tried first", host, e.describeHost())
+			}
+		}
+		if msg != "" {
+			issues = append(issues, LintIssue{Kind: LintHostOverlap, Host: mr.describeHost(), Message: msg})
+		}
+	}
+	return issues
+}
+
+func overlappingHost(a, b *muxRule) (string, bool) {
+	for _, s := range b.hostSamples() {
+		if a.match(s) {
+			return s, true
+		}
+	}
+	for _, s := range a.hostSamples() {
+		if b.match(s) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+func (mr *muxRule) hostSamples() []string {
+	if mr.host != "" {
+		return []string{mr.host}
+	}
+	return regexpSamples(mr.hostRegexp)
+}
+
+// lintRoutes reports the routes of the rule in their order.
+func lintRoutes(mr *muxRule) []LintIssue {
+	// the route lists a request is matched against, by route
+	leaves := map[*Route][]Routes{}
+	nodes := map[*Route][]*node{}
+	mr.root.walk(func(n *node) {
+		for _, r := range n.routes {
+			leaves[r] = append(leaves[r], n.routes)
+			nodes[r] = append(nodes[r], n)
+		}
+	})
+	for _, rs := range mr.pathCache {
+		for _, r := range rs {
+			leaves[r] = append(leaves[r], rs)
+		}
+	}
+
+	issues := []LintIssue{}
+	issue := func(kind string, r *Route, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{
+			Kind:    kind,
+			Host:    mr.describeHost(),
+			Route:   r.pattern,
+			Backend: r.backend,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for i, r := range mr.routes {
+		// a route with optional parts is shadowed if all its variants are
+		var first *Route
+		shadowed := len(leaves[r]) > 0
+		for _, rs := range leaves[r] {
+			s := rs.shadowing(r)
+			if s == nil {
+				shadowed = false
+				break
+			}
+			if first == nil {
+				first = s
+			}
+		}
+		if shadowed {
+			if first.sameAs(r) {
+				issue(LintDuplicate, r, "duplicate of the earlier route %s -> %s", first.pattern, first.backend)
+			} else {
+				issue(LintShadowed, r, "the earlier route %s -> %s takes all its requests", first.pattern, first.backend)
+			}
+		}
+
+		for _, n := range nodes[r] {
+			if a := n.ambiguous(r); a != nil {
+				issue(LintAmbiguousParams, r, "matches the same paths as the earlier route %s -> %s with other param names", a.pattern, a.backend)
+				break
+			}
+		}
+
+		if len(nodes[r]) > 0 {
+			reported := []string{}
+			for _, s := range mr.routes[i+1:] {
+				if !s.shadows(r) {
+					continue
+				}
+				for _, p := range s.patterns {
+					if patNextSegment(p).nodeType != ntStatic || StrInSlice(p, reported) || !r.matchesPath(p) {
+						continue
+					}
+					reported = append(reported, p)
+					issue(LintUnreachable, r, "the later static route %s -> %s takes %s, static routes are matched first", s.pattern, s.backend, p)
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+// walk calls fn for the node and the nodes below it.
+func (n *node) walk(fn func(n *node)) {
+	fn(n)
+	for _, nds := range n.children {
+		for _, child := range nds {
+			child.walk(fn)
+		}
+	}
+}
+
+// shadowing returns the first route before r taking all its requests.
+func (rs Routes) shadowing(r *Route) *Route {
+	for _, s := range rs {
+		if s == r {
+			return nil
+		}
+		if s.shadows(r) {
+			return s
+		}
+	}
+	return nil
+}
+
+// ambiguous returns the first route of the node before r with other param
+// names, the routes of a node have the same pattern but for the param names.
+func (n *node) ambiguous(r *Route) *Route {
+	keys := n.variantKeys(r)
+	for _, a := range n.routes {
+		if a == r {
+			return nil
+		}
+		if !equalStrings(keys, n.variantKeys(a)) {
+			return a
+		}
+	}
+	return nil
+}
+
+// variantKeys returns the param keys of the variant of the route on the node.
+func (n *node) variantKeys(r *Route) []string {
+	idx, ok := n.paramIdx[r]
+	if !ok {
+		return r.paramKeys
+	}
+	keys := make([]string, 0, len(idx))
+	for _, i := range idx {
+		keys = append(keys, r.paramKeys[i])
+	}
+	return keys
+}
+
+// shadows reports whether the route matches every request of r matching the
+// same node: it takes all the methods of r and its predicates are among the
+// predicates of r.
+func (s *Route) shadows(r *Route) bool {
+	if s.method&r.method != r.method {
+		return false
+	}
+	if s.pathRE != nil && (r.pathRE == nil || s.pathRE.String() != r.pathRE.String()) {
+		return false
+	}
+	predicates := r.predicates()
+	for _, p := range s.predicates() {
+		if !StrInSlice(p, predicates) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameAs reports whether the routes declare the same methods, pattern and
+// predicates, the backends may differ.
+func (s *Route) sameAs(r *Route) bool {
+	return s.pattern == r.pattern && s.pathType == r.pathType && s.method == r.method &&
+		r.shadows(s) && s.shadows(r)
+}
+
+// matchesPath reports whether the path matches a pattern of the route in the
+// tree, regardless of the methods and predicates.
+func (r *Route) matchesPath(path string) bool {
+	probe := &Route{patterns: r.patterns, paramKeys: r.paramKeys, pathRE: r.pathRE, method: mALL}
+	root := &node{}
+	for _, p := range r.patterns {
+		if patNextSegment(p).nodeType != ntStatic {
+			if _, err := root.insert(p, probe); err != nil {
+				return false
+			}
+		}
+	}
+	return root.find(path, &Context{method: mALL, path: path}) != nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// regexpSamples returns strings matching the regexp, the optional and repeated
+// parts are taken both none and once.
+func regexpSamples(expr string) []string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return regexpNodeSamples(re.Simplify())
+}
+
+func regexpNodeSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		return []string{string(classSample(re.Rune))}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"a"}
+	case syntax.OpCapture:
+		return regexpNodeSamples(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return append([]string{""}, regexpNodeSamples(re.Sub[0])...)
+	case syntax.OpPlus:
+		return regexpNodeSamples(re.Sub[0])
+	case syntax.OpRepeat:
+		samples := []string{""}
+		for i := 0; i < re.Min; i++ {
+			samples = concatSamples(samples, regexpNodeSamples(re.Sub[0]))
+		}
+		if re.Min == 0 && re.Max != 0 {
+			samples = append(samples, regexpNodeSamples(re.Sub[0])...)
+		}
+		return samples
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			samples = concatSamples(samples, regexpNodeSamples(sub))
+		}
+		return samples
+	case syntax.OpAlternate:
+		samples := []string{}
+		for _, sub := range re.Sub {
+			samples = append(samples, regexpNodeSamples(sub)...)
+		}
+		if len(samples) > maxRegexpSamples {
+			samples = samples[:maxRegexpSamples]
+		}
+		return samples
+	}
+	// the empty matches and the anchors
+	return []string{""}
+}
+
+func concatSamples(heads, tails []string) []string {
+	samples := []string{}
+	for _, h := range heads {
+		for _, t := range tails {
+			if len(samples) == maxRegexpSamples {
+				return samples
+			}
+			samples = append(samples, h+t)
+		}
+	}
+	return samples
+}
+
+// classSample picks a rune of a char class, a letter or a digit if possible
+// so that the sample looks like a host.
+func classSample(ranges []rune) rune {
+	for _, want := range []rune{'a', '0', '-'} {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= want && want <= ranges[i+1] {
+				return want
+			}
+		}
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for c := ranges[i]; c <= ranges[i+1]; c++ {
+			if unicode.IsPrint(c) && !strings.ContainsRune("./", c) {
+				return c
+			}
+		}
+	}
+	if len(ranges) > 0 {
+		return ranges[0]
+	}
+	return 'a'
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	get := []string{"GET"}
+	rules := []*Rule{
+		{
+			Host: "api.example.com",
+			Paths: []*Path{
+				{Path: "/article/{id}", Methods: get, Backend: "stub"},
+				{Path: "/article/{id}", Methods: get, Backend: "show"},
+				{Path: "/article/{id}", Methods: []string{"GET", "POST"}, Backend: "edit"},
+				{Path: "/article/{sup}/{opts}", Methods: get, Backend: "opts"},
+				{Path: "/article/{id}/{opts}", Methods: get, Backend: "opts2"},
+				{Path: "/article/{id}/{opts}", Methods: get, Headers: []*Header{{Key: "X-Beta", Values: []string{"1"}}}, Backend: "beta"},
+				{Path: "/users/{name}", Methods: get, Backend: "user"},
+				{Path: "/users/me", Backend: "me"},
+				{Path: "/users/{name}", Methods: get, Queries: []*Query{{Key: "v", Values: []string{"2"}}}, Backend: "user2"},
+				{Path: "/files", PathType: PathTypePrefix, Backend: "files"},
+				{Path: "/files/", Backend: "dir"},
+			},
+		},
+		{HostRegexp: `^[a-z]+\.example\.com$`, Paths: []*Path{{Path: "/", Backend: "wildcard"}}},
+		{Host: "www.example.com", Paths: []*Path{{Path: "/", Backend: "www"}}},
+		{HostRegexp: `^(www|api)\.example\.(com|org)$`, Paths: []*Path{{Path: "/", Backend: "both"}}},
+		{Paths: []*Path{{Path: "/", Backend: "any"}}},
+		{Host: "late.example.org", Paths: []*Path{{Path: "/", Backend: "late"}}},
+	}
+
+	issues, err := Lint(rules, false)
+	assert.Nil(t, err)
+	msgs := []string{}
+	for _, i := range issues {
+		msgs = append(msgs, i.String())
+	}
+	assert.Equal(t, []string{
+		"duplicate: host api.example.com: route /article/{id} -> show: duplicate of the earlier route /article/{id} -> stub",
+		"shadowed: host api.example.com: route /article/{id}/{opts} -> opts2: the earlier route /article/{sup}/{opts} -> opts takes all its requests",
+		"ambiguous-params: host api.example.com: route /article/{id}/{opts} -> opts2: matches the same paths as the earlier route /article/{sup}/{opts} -> opts with other param names",
+		"shadowed: host api.example.com: route /article/{id}/{opts} -> beta: the earlier route /article/{sup}/{opts} -> opts takes all its requests",
+		"ambiguous-params: host api.example.com: route /article/{id}/{opts} -> beta: matches the same paths as the earlier route /article/{sup}/{opts} -> opts with other param names",
+		"unreachable: host api.example.com: route /users/{name} -> user: the later static route /users/me -> me takes /users/me, static routes are matched first",
+		"shadowed: host api.example.com: route /users/{name} -> user2: the earlier route /users/{name} -> user takes all its requests",
+		"unreachable: host api.example.com: route /files -> files: the later static route /files/ -> dir takes /files/, static routes are matched first",
+		"host-overlap: host ^[a-z]+\\.example\\.com$: host api.example.com is also matched by the earlier rule for api.example.com, which is tried first",
+		"host-overlap: host www.example.com: host www.example.com is also matched by the earlier rule for ^[a-z]+\\.example\\.com$, which is tried first",
+		"host-overlap: host ^(www|api)\\.example\\.(com|org)$: host api.example.com is also matched by the earlier rule for api.example.com, which is tried first",
+		"host-overlap: host ^(www|api)\\.example\\.(com|org)$: host www.example.com is also matched by the earlier rule for ^[a-z]+\\.example\\.com$, which is tried first",
+		"host-overlap: host ^(www|api)\\.example\\.(com|org)$: host www.example.com is also matched by the earlier rule for www.example.com, which is tried first",
+		"host-overlap: host late.example.org: an earlier rule for any host is tried first",
+	}, msgs)
+
+	// the static routes are matched first in the tree without the path cache
+	issues, err = Lint(rules[:1], true)
+	assert.Nil(t, err)
+	unreachable := []string{}
+	for _, i := range issues {
+		if i.Kind == LintUnreachable {
+			unreachable = append(unreachable, i.String())
+		}
+	}
+	assert.Equal(t, []string{msgs[5], msgs[7]}, unreachable)
+
+	_, err = Lint([]*Rule{{Paths: []*Path{{Path: "/{id", Backend: "a"}}}}, false)
+	assert.NotNil(t, err)
+}
+
+func TestRegexpSamples(t *testing.T) {
+	assert.Equal(t, []string{"api.example.com"}, regexpSamples(`^api\.example\.com$`))
+	assert.Equal(t, []string{".example.com", "a.example.com"}, regexpSamples(`^(.*)\.example\.com$`))
+	assert.Equal(t, []string{"ab.ef", "ab.gh", "cd.ef", "cd.gh"}, regexpSamples(`^(ab|cd)\.(ef|gh)$`))
+	assert.Equal(t, []string{"0-0"}, regexpSamples(`^[0-9]{1}-\d$`))
+	assert.Nil(t, regexpSamples(`(`))
+}
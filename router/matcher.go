@@ -0,0 +1,89 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type (
+	// Matcher is a custom route predicate, it's evaluated after the method,
+	// header, query and vars checks of the route.
+	Matcher interface {
+		Match(context *Context) bool
+	}
+
+	// MatcherFunc adapts an ordinary function to the Matcher interface.
+	MatcherFunc func(context *Context) bool
+
+	// MatcherFactory builds a Matcher from the JSON arguments of a PathMatcher,
+	// it's called once when the router is built.
+	MatcherFactory func(args json.RawMessage) (Matcher, error)
+)
+
+var matcherRegistry = struct {
+	sync.RWMutex
+	factories map[string]MatcherFactory
+}{factories: make(map[string]MatcherFactory)}
+
+// Match calls f(context).
+func (f MatcherFunc) Match(context *Context) bool {
+	return f(context)
+}
+
+// RegisterMatcher makes a custom matcher available to PathMatcher by name,
+// registering the same name twice panics.
+func RegisterMatcher(name string, factory MatcherFactory) {
+	if name == "" || factory == nil {
+		panic("matcher name and factory must not be empty")
+	}
+
+	matcherRegistry.Lock()
+	defer matcherRegistry.Unlock()
+
+	if _, ok := matcherRegistry.factories[name]; ok {
+		panic(fmt.Sprintf("matcher '%s' is already registered", name))
+	}
+	matcherRegistry.factories[name] = factory
+}
+
+func newMatcher(pm *PathMatcher) (Matcher, error) {
+	matcherRegistry.RLock()
+	factory, ok := matcherRegistry.factories[pm.Matcher]
+	matcherRegistry.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown matcher '%s'", pm.Matcher)
+	}
+
+	m, err := factory(pm.Args)
+	if err != nil {
+		return nil, fmt.Errorf("matcher '%s': %v", pm.Matcher, err)
+	}
+	return m, nil
+}
+
+func newMatchers(pms []*PathMatcher) ([]Matcher, error) {
+	if len(pms) == 0 {
+		return nil, nil
+	}
+
+	matchers := make([]Matcher, 0, len(pms))
+	for _, pm := range pms {
+		m, err := newMatcher(pm)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func (r *Route) matchMatchers(context *Context) bool {
+	for _, m := range r.matchers {
+		if !m.Match(context) {
+			return false
+		}
+	}
+	return true
+}
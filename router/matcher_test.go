@@ -0,0 +1,108 @@
+package router
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type claimMatcher struct {
+	Claim string `json:"claim"`
+	Value string `json:"value"`
+}
+
+func (m *claimMatcher) Match(context *Context) bool {
+	return context.GetHeaders().Get("X-Claim-"+m.Claim) == m.Value
+}
+
+func init() {
+	RegisterMatcher("testClaim", func(args json.RawMessage) (Matcher, error) {
+		m := &claimMatcher{}
+		if err := json.Unmarshal(args, m); err != nil {
+			return nil, err
+		}
+		if m.Claim == "" {
+			return nil, fmt.Errorf("claim is required")
+		}
+		return m, nil
+	})
+
+	RegisterMatcher("testTLS", func(args json.RawMessage) (Matcher, error) {
+		return MatcherFunc(func(context *Context) bool {
+			return context.Request().TLS != nil
+		}), nil
+	})
+}
+
+func TestCustomMatcher(t *testing.T) {
+	var rules []*Rule
+	err := json.Unmarshal([]byte(`[{
+		"paths": [
+			{
+				"path": "/tenant/{id}",
+				"backend": "acme",
+				"methods": ["GET"],
+				"matchers": [{"matcher": "testClaim", "args": {"claim": "tenant", "value": "acme"}}]
+			},
+			{
+				"path": "/tenant/{id}",
+				"backend": "secure",
+				"matchers": [{"matcher": "testTLS"}]
+			},
+			{
+				"path": "/tenant/{id}",
+				"backend": "default"
+			}
+		]
+	}]`), &rules)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		m       string
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{m: "GET", r: "/tenant/1", h: "acme", headers: map[string]string{"X-Claim-Tenant": "acme"}},
+		{m: "POST", r: "/tenant/1", h: "default", headers: map[string]string{"X-Claim-Tenant": "acme"}},
+		{m: "GET", r: "/tenant/1", h: "default", headers: map[string]string{"X-Claim-Tenant": "other"}},
+		{m: "GET", r: "https://example.com/tenant/1", h: "secure"},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		if req.URL.Scheme == "https" {
+			req.TLS = &tls.ConnectionState{}
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s %v", tt.m, tt.r, tt.headers)
+	}
+}
+
+func TestCustomMatcherError(t *testing.T) {
+	_, err := newMatchers([]*PathMatcher{{Matcher: "missing"}})
+	assert.NotNil(t, err)
+
+	_, err = newMatchers([]*PathMatcher{{Matcher: "testClaim", Args: json.RawMessage(`{"value": "acme"}`)}})
+	assert.NotNil(t, err)
+
+	assert.Panics(t, func() {
+		RegisterMatcher("testClaim", func(args json.RawMessage) (Matcher, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,291 @@
+package router
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+type (
+	// mediaRange is a parsed media type or media range, e.g. application/*;q=0.8
+	mediaRange struct {
+		params  map[string]string
+		typ     string
+		subtype string
+		q       float64
+	}
+
+	// languageRange is a parsed language tag or language range, e.g. en-US;q=0.5
+	languageRange struct {
+		tag string
+		q   float64
+	}
+)
+
+// parseMediaRange parses a single media range, the q parameter is removed from
+// params and stored in q. ok is false if the value is malformed.
+func parseMediaRange(s string) (mr mediaRange, ok bool) {
+	mt, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return mr, false
+	}
+
+	idx := strings.IndexByte(mt, '/')
+	if idx <= 0 || idx == len(mt)-1 {
+		return mr, false
+	}
+
+	mr = mediaRange{
+		typ:     mt[:idx],
+		subtype: mt[idx+1:],
+		q:       1,
+	}
+
+	// */html is not a valid range
+	if mr.typ == "*" && mr.subtype != "*" {
+		return mr, false
+	}
+
+	if v, exists := params["q"]; exists {
+		mr.q = parseQuality(v)
+		delete(params, "q")
+	}
+
+	if len(params) > 0 {
+		mr.params = params
+	}
+
+	return mr, true
+}
+
+// parseAccept parses the value of an Accept or Content-Type like header into
+// a list of media ranges, malformed elements are ignored.
+func parseAccept(header string) []mediaRange {
+	ranges := make([]mediaRange, 0, strings.Count(header, ",")+1)
+	for _, s := range strings.Split(header, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if mr, ok := parseMediaRange(s); ok {
+			ranges = append(ranges, mr)
+		}
+	}
+	return ranges
+}
+
+// parseAcceptLanguage parses the value of an Accept-Language header,
+// malformed elements are ignored.
+func parseAcceptLanguage(header string) []languageRange {
+	ranges := make([]languageRange, 0, strings.Count(header, ",")+1)
+	for _, s := range strings.Split(header, ",") {
+		lr := languageRange{q: 1}
+
+		tag := s
+		if idx := strings.IndexByte(s, ';'); idx >= 0 {
+			tag = s[:idx]
+			param := strings.TrimSpace(s[idx+1:])
+			if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+				continue
+			}
+			lr.q = parseQuality(strings.TrimSpace(param[2:]))
+		}
+
+		lr.tag = strings.ToLower(strings.TrimSpace(tag))
+		if lr.tag == "" {
+			continue
+		}
+		ranges = append(ranges, lr)
+	}
+	return ranges
+}
+
+// parseQuality parses a qvalue, invalid values are treated as 0 (not acceptable).
+func parseQuality(s string) float64 {
+	q, err := strconv.ParseFloat(s, 64)
+	if err != nil || q < 0 || q > 1 {
+		return 0
+	}
+	return q
+}
+
+// matches reports whether the two media ranges overlap, either side may be
+// a wildcard. All parameters of mr must be present in t with the same value.
+func (mr *mediaRange) matches(t *mediaRange) bool {
+	if mr.typ != "*" && t.typ != "*" && mr.typ != t.typ {
+		return false
+	}
+	if mr.subtype != "*" && t.subtype != "*" && mr.subtype != t.subtype {
+		return false
+	}
+	for k, v := range mr.params {
+		if !strings.EqualFold(t.params[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// specificity orders media ranges as required by RFC 7231 section 5.3.2,
+// text/html;level=1 > text/html > text/* > */*.
+func (mr *mediaRange) specificity() int {
+	s := len(mr.params)
+	if mr.typ != "*" {
+		s += 1 << 10
+	}
+	if mr.subtype != "*" {
+		s += 1 << 11
+	}
+	return s
+}
+
+// mediaQuality returns the quality the accept ranges assign to the media type t,
+// the most specific matching range determines the quality.
+func mediaQuality(accept []mediaRange, t *mediaRange) float64 {
+	q, spec := 0.0, -1
+	for i := range accept {
+		if !accept[i].matches(t) {
+			continue
+		}
+		if s := accept[i].specificity(); s > spec {
+			q, spec = accept[i].q, s
+		}
+	}
+	return q
+}
+
+// matches reports whether the language range matches the language tag using the
+// basic filtering scheme of RFC 4647, "en" matches "en" and "en-US".
+func (lr *languageRange) matches(tag string) bool {
+	if lr.tag == "*" || lr.tag == tag {
+		return true
+	}
+	return len(tag) > len(lr.tag) && tag[len(lr.tag)] == '-' && strings.HasPrefix(tag, lr.tag)
+}
+
+// languageQuality returns the quality the accept ranges assign to the language tag,
+// the longest matching range determines the quality.
+func languageQuality(accept []languageRange, tag string) float64 {
+	q, spec := 0.0, -1
+	for i := range accept {
+		if !accept[i].matches(tag) {
+			continue
+		}
+		s := len(accept[i].tag)
+		if accept[i].tag == "*" {
+			s = 0
+		}
+		if s > spec {
+			q, spec = accept[i].q, s
+		}
+	}
+	return q
+}
+
+func (r *Route) negotiates() bool {
+	return len(r.produces) > 0 || len(r.languages) > 0
+}
+
+// quality returns the preference of the request for the representation served by
+// the route, 0 means not acceptable. A missing Accept or Accept-Language header
+// accepts everything with quality 1.
+func (r *Route) quality(context *Context) float64 {
+	q := 1.0
+
+	if len(r.produces) > 0 {
+		if accept := context.getAccept(); accept != nil {
+			best := 0.0
+			for i := range r.produces {
+				if mq := mediaQuality(accept, &r.produces[i]); mq > best {
+					best = mq
+				}
+			}
+			q *= best
+		}
+	}
+
+	if q > 0 && len(r.languages) > 0 {
+		if accept := context.getAcceptLanguage(); accept != nil {
+			best := 0.0
+			for _, tag := range r.languages {
+				if lq := languageQuality(accept, tag); lq > best {
+					best = lq
+				}
+			}
+			q *= best
+		}
+	}
+
+	return q
+}
+
+// matchContentType reports whether the Content-Type of the request is one of
+// the media ranges the route consumes.
+func (r *Route) matchContentType(context *Context) bool {
+	ct := context.getContentType()
+	if ct == nil {
+		return false
+	}
+	for i := range r.consumes {
+		if r.consumes[i].matches(ct) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Context) getAccept() []mediaRange {
+	if !c.acceptParsed {
+		c.acceptParsed = true
+		if v := c.GetHeaders().Values("Accept"); len(v) > 0 {
+			if accept := parseAccept(strings.Join(v, ",")); len(accept) > 0 {
+				c.accept = accept
+			}
+		}
+	}
+	return c.accept
+}
+
+func (c *Context) getAcceptLanguage() []languageRange {
+	if !c.acceptLanguageParsed {
+		c.acceptLanguageParsed = true
+		if v := c.GetHeaders().Values("Accept-Language"); len(v) > 0 {
+			if accept := parseAcceptLanguage(strings.Join(v, ",")); len(accept) > 0 {
+				c.acceptLanguage = accept
+			}
+		}
+	}
+	return c.acceptLanguage
+}
+
+func (c *Context) getContentType() *mediaRange {
+	if !c.contentTypeParsed {
+		c.contentTypeParsed = true
+		if v := c.GetHeaders().Get("Content-Type"); v != "" {
+			if mr, ok := parseMediaRange(v); ok {
+				c.contentType = &mr
+			}
+		}
+	}
+	return c.contentType
+}
+
+func newMediaRanges(types []string) []mediaRange {
+	ranges := make([]mediaRange, 0, len(types))
+	for _, t := range types {
+		mr, ok := parseMediaRange(t)
+		if !ok {
+			panic(fmt.Sprintf("invalid media type '%s' in route", t))
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+func newLanguageTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	for _, t := range tags {
+		res = append(res, strings.ToLower(strings.TrimSpace(t)))
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{
+					Path:     "/items",
+					Methods:  []string{"GET"},
+					Backend:  "v1",
+					Produces: []string{"application/vnd.acme.v1+json"},
+				},
+
+				{
+					Path:     "/items",
+					Methods:  []string{"GET"},
+					Backend:  "v2",
+					Produces: []string{"application/vnd.acme.v2+json"},
+				},
+
+				{
+					Path:    "/items",
+					Methods: []string{"GET"},
+					Backend: "fallback",
+				},
+
+				{
+					Path:     "/items",
+					Methods:  []string{"POST"},
+					Backend:  "create",
+					Consumes: []string{"application/*"},
+				},
+
+				{
+					Path:      "/docs/{page}",
+					Methods:   []string{"GET"},
+					Backend:   "docs-de",
+					Languages: []string{"de"},
+				},
+
+				{
+					Path:      "/docs/{page}",
+					Methods:   []string{"GET"},
+					Backend:   "docs-en",
+					Languages: []string{"en-US", "en-GB"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		m       string
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{m: "GET", r: "/items", h: "v1"},
+		{m: "GET", r: "/items", h: "v2", headers: map[string]string{"Accept": "application/vnd.acme.v2+json"}},
+		{m: "GET", r: "/items", h: "v2", headers: map[string]string{"Accept": "application/vnd.acme.v1+json;q=0.5, application/vnd.acme.v2+json"}},
+		{m: "GET", r: "/items", h: "v1", headers: map[string]string{"Accept": "application/*;q=0.4, application/vnd.acme.v2+json;q=0.2"}},
+		{m: "GET", r: "/items", h: "v1", headers: map[string]string{"Accept": "*/*"}},
+		{m: "GET", r: "/items", h: "fallback", headers: map[string]string{"Accept": "text/html"}},
+		{m: "GET", r: "/items", h: "fallback", headers: map[string]string{"Accept": "application/vnd.acme.v1+json;q=0"}},
+		{m: "POST", r: "/items", h: "create", headers: map[string]string{"Content-Type": "application/json; charset=utf-8"}},
+		{m: "POST", r: "/items", h: "", headers: map[string]string{"Content-Type": "text/plain"}},
+		{m: "POST", r: "/items", h: ""},
+		{m: "GET", r: "/docs/intro", h: "docs-de"},
+		{m: "GET", r: "/docs/intro", h: "docs-en", headers: map[string]string{"Accept-Language": "en"}},
+		{m: "GET", r: "/docs/intro", h: "docs-en", headers: map[string]string{"Accept-Language": "de;q=0.5, en-gb"}},
+		{m: "GET", r: "/docs/intro", h: "docs-de", headers: map[string]string{"Accept-Language": "fr, *;q=0.1"}},
+		{m: "GET", r: "/docs/intro", h: "", headers: map[string]string{"Accept-Language": "fr"}},
+	}
+
+	for _, disablePathCache := range []bool{false, true} {
+		router := New(rules, disablePathCache)
+		assert := assert.New(t)
+
+		for _, tt := range tests {
+			req, _ := http.NewRequest(tt.m, tt.r, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			context := router.Search(req)
+
+			var backend string
+
+			if context.Route != nil {
+				backend = context.Route.backend
+			}
+
+			assert.Equal(tt.h, backend, "%s %s %v", tt.m, tt.r, tt.headers)
+		}
+	}
+}
+
+func TestMatchQuality(t *testing.T) {
+	tests := []struct {
+		path   *Path
+		accept string
+		q      float64
+	}{
+		{path: &Path{}, accept: "text/html", q: 1},
+		{path: &Path{Methods: []string{"POST"}}, q: 0},
+		{path: &Path{Produces: []string{"application/json"}}, q: 1},
+		{path: &Path{Produces: []string{"application/json"}}, accept: "application/json;q=0.3, */*;q=0.1", q: 0.3},
+		{path: &Path{Produces: []string{"application/json"}}, accept: "text/html", q: 0},
+	}
+
+	for _, tt := range tests {
+		tt.path.Path, tt.path.Backend = "/a", "a"
+		r := newRoute(tt.path)
+
+		req, _ := http.NewRequest("GET", "/a", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		context := newContext(req)
+		assert.Equal(t, tt.q, r.matchQuality(context), tt.accept)
+		assert.Equal(t, tt.q > 0, r.match(context), tt.accept)
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	assert := assert.New(t)
+
+	ranges := parseAccept("text/html;level=1, text/*;q=0.3, bogus, */*;q=0.1")
+	assert.Len(ranges, 3)
+	assert.Equal("html", ranges[0].subtype)
+	assert.Equal(map[string]string{"level": "1"}, ranges[0].params)
+	assert.Equal(0.3, ranges[1].q)
+
+	html := mediaRange{typ: "text", subtype: "html"}
+	level1 := mediaRange{typ: "text", subtype: "html", params: map[string]string{"level": "1"}}
+	plain := mediaRange{typ: "text", subtype: "plain"}
+	image := mediaRange{typ: "image", subtype: "png"}
+	assert.Equal(1.0, mediaQuality(ranges, &level1))
+	assert.Equal(0.3, mediaQuality(ranges, &html))
+	assert.Equal(0.3, mediaQuality(ranges, &plain))
+	assert.Equal(0.1, mediaQuality(ranges, &image))
+
+	langs := parseAcceptLanguage("da, en-gb;q=0.8, en;q=0.7, ;q=1")
+	assert.Len(langs, 3)
+	assert.Equal(0.8, languageQuality(langs, "en-gb"))
+	assert.Equal(0.7, languageQuality(langs, "en-us"))
+	assert.Equal(0.0, languageQuality(langs, "de"))
+}
@@ -0,0 +1,241 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// OpenAPIOptions configures ImportOpenAPI.
+	OpenAPIOptions struct {
+		// OperationBackends maps operation ids to backends, it takes precedence
+		// over TagBackends.
+		OperationBackends map[string]string
+		// TagBackends maps tags to backends, the first tag of an operation with
+		// a backend is used.
+		TagBackends map[string]string
+		// DefaultBackend is the backend of the operations without a mapping,
+		// they are left out if it's empty.
+		DefaultBackend string
+		// BasePath is prepended to the paths of the document, e.g. /v1.
+		BasePath string
+		// TypedParams derives param types from the schemas of the path params,
+		// e.g. /pets/{petId:int} for an integer petId.
+		TypedParams bool
+	}
+
+	openAPIDocument struct {
+		OpenAPI    string    `yaml:"openapi"`
+		Paths      yaml.Node `yaml:"paths"`
+		Components struct {
+			Parameters map[string]*openAPIParameter `yaml:"parameters"`
+		} `yaml:"components"`
+	}
+
+	openAPIOperation struct {
+		OperationID string              `yaml:"operationId"`
+		Tags        []string            `yaml:"tags"`
+		Parameters  []*openAPIParameter `yaml:"parameters"`
+	}
+
+	openAPIParameter struct {
+		Ref    string         `yaml:"$ref"`
+		Name   string         `yaml:"name"`
+		In     string         `yaml:"in"`
+		Schema *openAPISchema `yaml:"schema"`
+	}
+
+	openAPISchema struct {
+		Type    string        `yaml:"type"`
+		Format  string        `yaml:"format"`
+		Minimum *float64      `yaml:"minimum"`
+		Enum    []interface{} `yaml:"enum"`
+	}
+)
+
+// openAPIMethods are the operation fields of a path item in the order of the spec.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// ImportOpenAPI builds paths from the operations of an OpenAPI 3 document in
+// JSON or YAML, the operations of a path with the same backend share a path.
+// The paths keep the order of the document, the servers are ignored as the
+// hosts are a matter of the deployment.
+//
+// With TypedParams, an integer param becomes {id:int}, or {id:uint} with a
+// non-negative minimum, a string with the uuid or date format {id:uuid} or
+// {id:date} and an enum a regexp of the values.
+func ImportOpenAPI(data []byte, opts *OpenAPIOptions) ([]*Path, []ImportWarning, error) {
+	if opts == nil {
+		opts = &OpenAPIOptions{}
+	}
+
+	doc := &openAPIDocument{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, nil, fmt.Errorf("unsupported OpenAPI version '%s'", doc.OpenAPI)
+	}
+	if doc.Paths.Kind != 0 && doc.Paths.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("paths: expected a mapping")
+	}
+
+	paths := []*Path{}
+	warnings := []ImportWarning{}
+
+	// the content of a mapping node alternates keys and values
+	for i := 0; i+1 < len(doc.Paths.Content); i += 2 {
+		template := doc.Paths.Content[i].Value
+		item := doc.Paths.Content[i+1]
+
+		warn := func(field, reason string) {
+			warnings = append(warnings, ImportWarning{Route: template, Field: field, Reason: reason})
+		}
+
+		var fields map[string]yaml.Node
+		if err := item.Decode(&fields); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", template, err)
+		}
+
+		var common []*openAPIParameter
+		if node, ok := fields["parameters"]; ok {
+			if err := node.Decode(&common); err != nil {
+				return nil, nil, fmt.Errorf("%s: parameters: %v", template, err)
+			}
+		}
+		if _, ok := fields["$ref"]; ok {
+			warn("$ref", "path item references are not supported, the path is left out")
+			continue
+		}
+
+		byBackend := map[string]*Path{}
+
+		for _, method := range openAPIMethods {
+			node, ok := fields[method]
+			if !ok {
+				continue
+			}
+			op := &openAPIOperation{}
+			if err := node.Decode(op); err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %v", template, method, err)
+			}
+
+			backend := openAPIBackend(op, opts)
+			if backend == "" {
+				warn(method, "no backend for the operation, it's left out")
+				continue
+			}
+
+			pattern, err := openAPIPattern(template, doc, common, op.Parameters, opts)
+			if err != nil {
+				warn(method, err.Error()+", the operation is left out")
+				continue
+			}
+
+			// operations with different typed params cannot share a path
+			key := backend + " " + pattern
+			path, ok := byBackend[key]
+			if !ok {
+				path = &Path{Path: pattern, Backend: backend}
+				if err := checkPath(path); err != nil {
+					warn(method, err.Error()+", the operation is left out")
+					continue
+				}
+				byBackend[key] = path
+				paths = append(paths, path)
+			}
+			path.Methods = append(path.Methods, strings.ToUpper(method))
+		}
+	}
+
+	return paths, warnings, nil
+}
+
+func openAPIBackend(op *openAPIOperation, opts *OpenAPIOptions) string {
+	if backend, ok := opts.OperationBackends[op.OperationID]; ok && op.OperationID != "" {
+		return backend
+	}
+	for _, tag := range op.Tags {
+		if backend, ok := opts.TagBackends[tag]; ok {
+			return backend
+		}
+	}
+	return opts.DefaultBackend
+}
+
+// openAPIPattern converts the path template into a pattern, the params of the
+// operation override those of the path item.
+func openAPIPattern(template string, doc *openAPIDocument, common, params []*openAPIParameter, opts *OpenAPIOptions) (string, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", fmt.Errorf("path must start with /")
+	}
+	if strings.ContainsAny(template, "*[]") {
+		return "", fmt.Errorf("path contains unsupported characters")
+	}
+
+	pattern := strings.TrimRight(opts.BasePath, "/") + template
+	if !opts.TypedParams {
+		return pattern, nil
+	}
+
+	types := map[string]string{}
+	for _, list := range [][]*openAPIParameter{common, params} {
+		for _, p := range list {
+			if p.Ref != "" {
+				name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+				ref, ok := doc.Components.Parameters[name]
+				if !ok || name == p.Ref {
+					return "", fmt.Errorf("unresolved parameter reference %s", p.Ref)
+				}
+				p = ref
+			}
+			if p.In == "path" {
+				types[p.Name] = openAPIParamType(p.Schema)
+			}
+		}
+	}
+
+	for name, typ := range types {
+		if typ != "" {
+			pattern = strings.ReplaceAll(pattern, "{"+name+"}", "{"+name+":"+typ+"}")
+		}
+	}
+	return pattern, nil
+}
+
+// openAPIParamType returns the param type or regexp for a schema, or an empty
+// string if the schema doesn't constrain a path segment.
+func openAPIParamType(schema *openAPISchema) string {
+	if schema == nil {
+		return ""
+	}
+
+	if len(schema.Enum) > 0 {
+		values := make([]string, 0, len(schema.Enum))
+		for _, v := range schema.Enum {
+			s := toString(v)
+			if s == "" || strings.ContainsAny(s, "/{}") {
+				return ""
+			}
+			values = append(values, regexp.QuoteMeta(s))
+		}
+		return "(?:" + strings.Join(values, "|") + ")"
+	}
+
+	switch schema.Type {
+	case "integer":
+		if schema.Minimum != nil && *schema.Minimum >= 0 {
+			return "uint"
+		}
+		return "int"
+	case "string":
+		switch schema.Format {
+		case "uuid", "date":
+			return schema.Format
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testOpenAPI = `
+openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pets]
+    post:
+      operationId: createPet
+      tags: [pets]
+  /pets/{petId}:
+    parameters:
+    - $ref: '#/components/parameters/PetId'
+    get:
+      operationId: showPetById
+      tags: [pets]
+    delete:
+      operationId: deletePet
+      tags: [admin, pets]
+  /pets/{petId}/photos/{photoId}:
+    get:
+      tags: [photos]
+      parameters:
+      - {name: petId, in: path, schema: {type: integer, minimum: 1}}
+      - {name: photoId, in: path, schema: {type: string, format: uuid}}
+  /stores/{kind}:
+    get:
+      tags: [stores]
+      parameters:
+      - {name: kind, in: path, schema: {type: string, enum: [retail, online]}}
+  /health:
+    get:
+      operationId: health
+components:
+  parameters:
+    PetId:
+      name: petId
+      in: path
+      required: true
+      schema:
+        type: integer
+`
+
+func TestImportOpenAPI(t *testing.T) {
+	opts := &OpenAPIOptions{
+		OperationBackends: map[string]string{"deletePet": "pets-admin"},
+		TagBackends:       map[string]string{"pets": "pets", "photos": "photos", "stores": "stores"},
+		BasePath:          "/v1/",
+		TypedParams:       true,
+	}
+
+	paths, warnings, err := ImportOpenAPI([]byte(testOpenAPI), opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Path{
+		{Path: "/v1/pets", Backend: "pets", Methods: []string{"GET", "POST"}},
+		{Path: "/v1/pets/{petId:int}", Backend: "pets", Methods: []string{"GET"}},
+		{Path: "/v1/pets/{petId:int}", Backend: "pets-admin", Methods: []string{"DELETE"}},
+		{Path: "/v1/pets/{petId:uint}/photos/{photoId:uuid}", Backend: "photos", Methods: []string{"GET"}},
+		{Path: "/v1/stores/{kind:(?:retail|online)}", Backend: "stores", Methods: []string{"GET"}},
+	}, paths)
+	assert.Equal(t, []ImportWarning{
+		{Route: "/health", Field: "get", Reason: "no backend for the operation, it's left out"},
+	}, warnings)
+
+	tests := []struct {
+		m string
+		r string
+		h string
+	}{
+		{m: "GET", r: "/v1/pets", h: "pets"},
+		{m: "GET", r: "/v1/pets/1", h: "pets"},
+		{m: "GET", r: "/v1/pets/x", h: ""},
+		{m: "DELETE", r: "/v1/pets/1", h: "pets-admin"},
+		{m: "GET", r: "/v1/pets/1/photos/6ba7b810-9dad-11d1-80b4-00c04fd430c8", h: "photos"},
+		{m: "GET", r: "/v1/pets/-1/photos/6ba7b810-9dad-11d1-80b4-00c04fd430c8", h: ""},
+		{m: "GET", r: "/v1/stores/online", h: "stores"},
+		{m: "GET", r: "/v1/stores/other", h: ""},
+	}
+
+	router := New([]*Rule{{Paths: paths}}, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s", tt.m, tt.r)
+	}
+
+	paths, _, err = ImportOpenAPI([]byte(`{"openapi": "3.1.0", "paths": {"/pets/{petId}": {"get": {}}}}`), &OpenAPIOptions{DefaultBackend: "api"})
+	assert.Nil(t, err)
+	assert.Equal(t, []*Path{{Path: "/pets/{petId}", Backend: "api", Methods: []string{"GET"}}}, paths)
+
+	_, _, err = ImportOpenAPI([]byte(`{"swagger": "2.0"}`), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,174 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Param types are named constraints for route params, e.g. /users/{id:int}.
+// They are matched by plain functions instead of regexps, a registered type
+// name takes precedence over a regexp with the same text.
+
+var paramTypes = struct {
+	sync.RWMutex
+	checks map[string]func(string) bool
+}{
+	checks: map[string]func(string) bool{
+		"int":   isInt,
+		"uint":  isUint,
+		"uuid":  isUUID,
+		"hex":   isHex,
+		"alpha": isAlpha,
+		"alnum": isAlnum,
+		"slug":  isSlug,
+		"date":  isDate,
+	},
+}
+
+// RegisterParamType registers a named param type usable as {name:typ} in route
+// patterns, registering an existing name panics.
+func RegisterParamType(name string, check func(string) bool) {
+	if name == "" || check == nil || strings.ContainsAny(name, "{}/") {
+		panic(fmt.Sprintf("invalid param type '%s'", name))
+	}
+
+	paramTypes.Lock()
+	defer paramTypes.Unlock()
+
+	if _, ok := paramTypes.checks[name]; ok {
+		panic(fmt.Sprintf("param type '%s' is already registered", name))
+	}
+	paramTypes.checks[name] = check
+}
+
+func lookupParamType(name string) func(string) bool {
+	paramTypes.RLock()
+	defer paramTypes.RUnlock()
+	return paramTypes.checks[name]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isInt matches -?[0-9]+
+func isInt(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	return isUint(s)
+}
+
+// isUint matches [0-9]+
+func isUint(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isHex matches [0-9a-fA-F]+
+func isHex(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isHexDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID matches 8-4-4-4-12 hex digits
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isAlpha matches [a-zA-Z]+
+func isAlpha(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isLetter(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAlnum matches [a-zA-Z0-9]+
+func isAlnum(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isLetter(s[i]) && !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSlug matches [a-z0-9]+(-[a-z0-9]+)*
+func isSlug(s string) bool {
+	if len(s) == 0 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '-' {
+			if s[i-1] == '-' {
+				return false
+			}
+			continue
+		}
+		if !(c >= 'a' && c <= 'z') && !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDate matches YYYY-MM-DD with month 01-12 and day 01-31
+func isDate(s string) bool {
+	if len(s) != 10 || s[4] != '-' || s[7] != '-' {
+		return false
+	}
+	if !isUint(s[:4]) || !isUint(s[5:7]) || !isUint(s[8:]) {
+		return false
+	}
+	month := int(s[5]-'0')*10 + int(s[6]-'0')
+	day := int(s[8]-'0')*10 + int(s[9]-'0')
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
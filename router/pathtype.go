@@ -0,0 +1,92 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// PathTypeExact matches the literal path only.
+	PathTypeExact = "Exact"
+	// PathTypePrefix matches the path and everything below it on segment
+	// boundaries, /foo matches /foo and /foo/bar but not /foobar.
+	PathTypePrefix = "Prefix"
+	// PathTypeRegularExpression matches the whole path against a regexp. The
+	// route is a catch-all below the literal prefix of the regexp, which is
+	// matched once the search reaches it: the static paths and the params
+	// below the prefix are tried first, and the route competes in declaration
+	// order with the other catch-alls of the prefix. A path the regexp
+	// rejects falls through to them and then to the shorter prefixes.
+	PathTypeRegularExpression = "RegularExpression"
+	// PathTypeImplementationSpecific uses the pattern syntax of the router,
+	// it's the same as an empty path type.
+	PathTypeImplementationSpecific = "ImplementationSpecific"
+)
+
+// pathPatterns returns the patterns the path is inserted into the tree with,
+// and the regexp the whole path must match for regular expression paths.
+func pathPatterns(path *Path) ([]string, *regexp.Regexp) {
+	switch path.PathType {
+	case "", PathTypeImplementationSpecific:
+		return expandPattern(path.Path), nil
+
+	case PathTypeExact:
+		if strings.ContainsAny(path.Path, "{}*[]") {
+			panic(fmt.Sprintf("exact path '%s' must not contain pattern characters", path.Path))
+		}
+		return []string{path.Path}, nil
+
+	case PathTypePrefix:
+		if strings.ContainsAny(path.Path, "{}*[]") {
+			panic(fmt.Sprintf("prefix path '%s' must not contain pattern characters", path.Path))
+		}
+		// the trailing slash is ignored, /foo/ is the same as /foo
+		prefix := strings.TrimRight(path.Path, "/")
+		return expandPattern(prefix + "[/*]"), nil
+
+	case PathTypeRegularExpression:
+		re, err := regexp.Compile("^(?:" + path.Path + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("invalid regexp path '%s': %v", path.Path, err))
+		}
+
+		// the route hangs off the tree below the literal prefix of the regexp,
+		// the regexp filters the routes of the catch-all
+		prefix, _ := re.LiteralPrefix()
+		if idx := strings.IndexAny(prefix, "{}*[]"); idx >= 0 {
+			prefix = prefix[:idx]
+		}
+		return []string{prefix + "*"}, re
+
+	case PathTypeServeMux:
+		if !strings.HasPrefix(path.Path, "/") {
+			panic(fmt.Sprintf("ServeMux path '%s' must start with /", path.Path))
+		}
+		segments, err := parseServeMuxPath(path.Path)
+		if err != nil {
+			panic(fmt.Sprintf("invalid ServeMux path '%s': %v", path.Path, err))
+		}
+		p := &serveMuxPattern{segments: segments}
+		return []string{p.routerPattern()}, nil
+	}
+
+	panic(fmt.Sprintf("unknown path type '%s'", path.PathType))
+}
+
+// insertRoute appends the route to the list, except for an exact route which
+// is inserted before the prefix routes, so that it takes precedence for the
+// same path as required for Ingress.
+func (rs Routes) insertRoute(r *Route) Routes {
+	if r.pathType == PathTypeExact {
+		for i, rr := range rs {
+			if rr.pathType == PathTypePrefix {
+				rs = append(rs, nil)
+				copy(rs[i+1:], rs[i:])
+				rs[i] = r
+				return rs
+			}
+		}
+	}
+	return append(rs, r)
+}
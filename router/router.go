@@ -22,11 +22,21 @@ type (
 
 	// Represents leaf node in radix tree
 	Route struct {
+		// path is the configuration the route is built from
+		path           *Path
 		pattern        string
 		backend        string
 		headers        []*Header
 		queries        []*Query
 		paramKeys      []string
+		patterns       []string
+		pathType       string
+		pathRE         *regexp.Regexp
+		vars           expr
+		matchers       []Matcher
+		produces       []mediaRange
+		consumes       []mediaRange
+		languages      []string
 		method         methodType
 		matchAllHeader bool
 	}
@@ -38,8 +48,15 @@ type (
 		// regexp matcher for regexp nodes
 		rex *regexp.Regexp
 
+		// check function for regexp nodes of a named param type
+		check func(string) bool
+
 		// HTTP handler endpoints on the leaf node
-		routes []*Route
+		routes Routes
+
+		// positions of the param values in the route params, only for
+		// routes with optional parts, keyed by route
+		paramIdx map[*Route][]int
 
 		// prefix is the common prefix we ignore
 		prefix string
@@ -51,7 +68,7 @@ type (
 		// first byte of the child prefix
 		tail byte
 
-		// node type: static, regexp, param, catchAll
+		// node type: static, regexp, param, multiParam, catchAll
 		typ nodeType
 
 		// first byte of the prefix
@@ -66,6 +83,7 @@ type (
 		hostRE           *regexp.Regexp
 		root             *node
 		pathCache        PathCache
+		routes           []*Route // in the order of the paths
 		host             string
 		hostRegexp       string
 		disablePathCache bool
@@ -81,13 +99,24 @@ type (
 
 	// Context is the default routing Context
 	Context struct {
-		headers     http.Header
-		queries     url.Values
-		Route       *Route
-		request     *http.Request
-		path        string
-		routeParams routeParams
-		method      methodType
+		headers        http.Header
+		queries        url.Values
+		Route          *Route
+		request        *http.Request
+		contentType    *mediaRange
+		path           string
+		accept         []mediaRange
+		acceptLanguage []languageRange
+		routeParams    routeParams
+		method         methodType
+		// trace records the search for SearchExplain, nil otherwise
+		trace *searchTrace
+		// all collects the matching routes for SearchAll, nil otherwise
+		all *searchAll
+
+		acceptParsed         bool
+		acceptLanguageParsed bool
+		contentTypeParsed    bool
 	}
 )
 
@@ -120,10 +149,11 @@ var methodMap = map[string]methodType{
 }
 
 const (
-	ntStatic   nodeType = iota // /home
-	ntRegexp                   // /{id:[0-9]+}
-	ntParam                    // /{user}
-	ntCatchAll                 // /api/v1/*
+	ntStatic     nodeType = iota // /home
+	ntRegexp                     // /{id:[0-9]+}, /{id:int}
+	ntParam                      // /{user}
+	ntMultiParam                 // /{path...}/raw
+	ntCatchAll                   // /api/v1/*
 )
 
 // Sort the list of nodes by label
@@ -214,7 +244,15 @@ func (n *node) addChild(child *node, prefix string) *node {
 			// Route starts with a param
 			child.typ = segType
 
-			if segType == ntRegexp {
+			if segType == ntCatchAll {
+				// named and unnamed wildcards share the same edge
+				child.label = '*'
+			}
+
+			if segType == ntRegexp && seg.check != nil {
+				child.prefix = seg.rexpat
+				child.check = seg.check
+			} else if segType == ntRegexp {
 				rex, err := regexp.Compile(seg.rexpat)
 				if err != nil {
 					panic(fmt.Sprintf("invalid regexp pattern '%s' in route param", seg.rexpat))
@@ -252,6 +290,7 @@ func (n *node) addChild(child *node, prefix string) *node {
 			child.typ = ntStatic
 			child.prefix = search[:ps]
 			child.rex = nil
+			child.check = nil
 
 			// add the param edge node
 			search = search[ps:]
@@ -262,6 +301,9 @@ func (n *node) addChild(child *node, prefix string) *node {
 				tail:   seg.tail,
 				prefix: search,
 			}
+			if segType == ntCatchAll {
+				nn.label = '*'
+			}
 			hn = child.addChild(nn, search)
 		}
 	}
@@ -287,22 +329,35 @@ func (n *node) replaceChild(label, tail byte, child *node) {
 	panic("replacing missing child")
 }
 
-func (n *node) setRoute(path *Path) {
+func (n *node) setRoute(r *Route, pattern string) {
 	if n.routes == nil {
-		n.routes = make([]*Route, 0)
+		n.routes = make(Routes, 0)
 	}
 
-	r := newRoute(path)
-	n.routes = append(n.routes, r)
+	// several optional variants of a route may end up on the same node
+	for _, rr := range n.routes {
+		if rr == r {
+			return
+		}
+	}
+
+	n.routes = n.routes.insertRoute(r)
+
+	if len(r.patterns) > 1 {
+		if n.paramIdx == nil {
+			n.paramIdx = make(map[*Route][]int)
+		}
+		n.paramIdx[r] = r.paramIndexes(pattern)
+	}
 }
 
-func (root *node) insert(path *Path) (*node, error) {
-	if path == nil {
+func (root *node) insert(pattern string, r *Route) (*node, error) {
+	if r == nil {
 		panic("param invalid")
 	}
 
-	// search := normalizePath(path.Path)
-	search := path.Path
+	// search := normalizePath(pattern)
+	search := pattern
 
 	var parent *node
 	n := root
@@ -310,7 +365,7 @@ func (root *node) insert(path *Path) (*node, error) {
 	for {
 
 		if len(search) == 0 {
-			n.setRoute(path)
+			n.setRoute(r, pattern)
 			return n, nil
 		}
 
@@ -319,6 +374,9 @@ func (root *node) insert(path *Path) (*node, error) {
 		var seg segment
 		if label == '{' || label == '*' {
 			seg = patNextSegment(search)
+			if seg.nodeType == ntCatchAll {
+				label = '*'
+			}
 		}
 
 		parent = n
@@ -327,7 +385,7 @@ func (root *node) insert(path *Path) (*node, error) {
 		if n == nil {
 			child := &node{label: label, tail: seg.tail, prefix: search}
 			hn := parent.addChild(child, search)
-			hn.setRoute(path)
+			hn.setRoute(r, pattern)
 			return hn, nil
 		}
 
@@ -358,7 +416,7 @@ func (root *node) insert(path *Path) (*node, error) {
 
 		search = search[commonPrefix:]
 		if len(search) == 0 {
-			child.setRoute(path)
+			child.setRoute(r, pattern)
 			return child, nil
 		}
 
@@ -369,26 +427,87 @@ func (root *node) insert(path *Path) (*node, error) {
 		}
 
 		hn := child.addChild(subChild, search)
-		hn.setRoute(path)
+		hn.setRoute(r, pattern)
 		return hn, nil
 
 	}
 }
 
 func (n *node) match(context *Context) *Route {
-	for _, r := range n.routes {
-		if r.match(context) {
+	return n.routes.match(context)
+}
+
+// matchLeaf matches the routes of a leaf node, in the mode of SearchAll it
+// collects all the matching routes and returns nil to go on with the search.
+func (n *node) matchLeaf(context *Context) *Route {
+	if context.all != nil {
+		context.all.collect(n, context)
+		return nil
+	}
+	return n.match(context)
+}
+
+// alignParams spreads the param values captured for an optional variant of
+// the route over all its params, the absent ones are left empty.
+func (n *node) alignParams(context *Context, r *Route) {
+	if values, ok := n.alignedParams(context.routeParams.Values, r); ok {
+		context.routeParams.Values = values
+	}
+}
+
+func (n *node) alignedParams(captured []string, r *Route) ([]string, bool) {
+	idx, ok := n.paramIdx[r]
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]string, len(r.paramKeys))
+	captured = captured[len(captured)-len(idx):]
+	for i, j := range idx {
+		values[j] = captured[i]
+	}
+	return values, true
+}
+
+// match returns the first route matching the context. Routes with content
+// negotiation compete by the quality the request assigns to them, the first
+// matching route without negotiation ends the competition and is only chosen
+// if none of the negotiating routes declared before it is acceptable.
+func (rs Routes) match(context *Context) *Route {
+	var best *Route
+	var bestQ float64
+
+	for _, r := range rs {
+		q := r.matchQuality(context)
+		context.trace.route(context, r, q)
+		if q == 0 {
+			continue
+		}
+
+		if !r.negotiates() {
+			if best != nil {
+				return best
+			}
 			return r
 		}
+
+		if q > bestQ {
+			best, bestQ = r, q
+		}
 	}
 
-	return nil
+	return best
 }
 
 func (n *node) find(path string, context *Context) *Route {
 	nn := n
 	search := path
 
+	if context.trace != nil {
+		context.trace.depth++
+		defer func() { context.trace.depth-- }()
+	}
+
 	for t, nds := range nn.children {
 
 		if len(nds) == 0 {
@@ -414,15 +533,21 @@ func (n *node) find(path string, context *Context) *Route {
 			}
 
 			xn = nds.findEdge(label)
-			if xn == nil || !strings.HasPrefix(xsearch, xn.prefix) {
+			if xn == nil {
+				continue
+			}
+			if !strings.HasPrefix(xsearch, xn.prefix) {
+				context.trace.node(xn, "", "prefix mismatch")
 				continue
 			}
+			context.trace.node(xn, "", "")
 			xsearch = xsearch[len(xn.prefix):]
 
 			if len(xsearch) == 0 {
 				if xn.isLeaf() {
-					r := xn.match(context)
+					r := xn.matchLeaf(context)
 					if r != nil {
+						xn.alignParams(context, r)
 						// context.routeParams.Keys = append(context.routeParams.Keys, r.paramKeys...)
 						return r
 					}
@@ -456,12 +581,20 @@ func (n *node) find(path string, context *Context) *Route {
 				}
 
 				if ntype == ntRegexp {
-					if !xn.rex.MatchString(xsearch[:p]) {
+					if xn.check != nil {
+						if !xn.check(xsearch[:p]) {
+							context.trace.node(xn, xsearch[:p], "not of type "+xn.prefix)
+							continue
+						}
+					} else if !xn.rex.MatchString(xsearch[:p]) {
+						context.trace.node(xn, xsearch[:p], "regexp mismatch")
 						continue
 					}
 				} else if strings.IndexByte(xsearch[:p], '/') != -1 {
+					context.trace.node(xn, xsearch[:p], "contains /")
 					continue
 				}
+				context.trace.node(xn, xsearch[:p], "")
 
 				prevlen := len(context.routeParams.Values)
 				context.routeParams.Values = append(context.routeParams.Values, xsearch[:p])
@@ -469,8 +602,9 @@ func (n *node) find(path string, context *Context) *Route {
 
 				if len(xsearch) == 0 {
 					if xn.isLeaf() {
-						r := xn.match(context)
+						r := xn.matchLeaf(context)
 						if r != nil {
+							xn.alignParams(context, r)
 							return r
 						}
 					}
@@ -483,13 +617,29 @@ func (n *node) find(path string, context *Context) *Route {
 				xsearch = search
 			}
 
+		case ntMultiParam:
+			if xsearch == "" {
+				continue
+			}
+
+			for idx := 0; idx < len(nds); idx++ {
+				xn = nds[idx]
+				if r := xn.findMulti(xsearch, context); r != nil {
+					return r
+				}
+			}
+
 		default:
 			xn = nds[0]
-			r := xn.match(context)
+			context.trace.node(xn, xsearch, "")
+			prevlen := len(context.routeParams.Values)
+			context.routeParams.Values = append(context.routeParams.Values, xsearch)
+			r := xn.matchLeaf(context)
 			if r != nil {
-				context.routeParams.Values = append(context.routeParams.Values, xsearch)
+				xn.alignParams(context, r)
 				return r
 			}
+			context.routeParams.Values = context.routeParams.Values[:prevlen]
 		}
 
 	}
@@ -497,25 +647,96 @@ func (n *node) find(path string, context *Context) *Route {
 	return nil
 }
 
+// findMulti matches a multi-segment param node, the value may contain slashes.
+// The shortest value followed by the tail of the node is tried first, then the
+// next longer one and so on, like a non-greedy regexp. A param at the end of
+// the pattern may capture the whole remaining path.
+func (n *node) findMulti(search string, context *Context) *Route {
+	prevlen := len(context.routeParams.Values)
+
+	for p := 1; p <= len(search); p++ {
+		if p < len(search) {
+			next := strings.IndexByte(search[p:], n.tail)
+			if next < 0 {
+				if n.tail != '/' {
+					return nil
+				}
+				p = len(search)
+			} else {
+				p += next
+			}
+		} else if n.tail != '/' {
+			return nil
+		}
+
+		context.routeParams.Values = append(context.routeParams.Values, search[:p])
+		xsearch := search[p:]
+		context.trace.node(n, search[:p], "")
+
+		if len(xsearch) == 0 {
+			if n.isLeaf() {
+				r := n.matchLeaf(context)
+				if r != nil {
+					n.alignParams(context, r)
+					return r
+				}
+			}
+		}
+		fin := n.find(xsearch, context)
+		if fin != nil {
+			return fin
+		}
+		context.routeParams.Values = context.routeParams.Values[:prevlen]
+	}
+
+	return nil
+}
+
 func (n *node) isLeaf() bool {
 	return n.routes != nil
 }
 
 func (r *Route) match(context *Context) bool {
+	return r.matchQuality(context) > 0
+}
+
+// matchQuality returns 0 if the route does not match the context, else the
+// quality of the route for the request, 1 for a route without negotiation.
+func (r *Route) matchQuality(context *Context) float64 {
 	// method match
 	if context.method&r.method == 0 {
-		return false
+		return 0
+	}
+
+	if r.pathRE != nil && !r.pathRE.MatchString(context.path) {
+		return 0
 	}
 
 	if len(r.headers) > 0 && !r.matchHeaders(context.GetHeaders()) {
-		return false
+		return 0
 	}
 
 	if len(r.queries) > 0 && !r.matchQueries(context.GetQueries()) {
-		return false
+		return 0
 	}
 
-	return true
+	if r.vars != nil && !r.vars.eval(context) {
+		return 0
+	}
+
+	if len(r.matchers) > 0 && !r.matchMatchers(context) {
+		return 0
+	}
+
+	if len(r.consumes) > 0 && !r.matchContentType(context) {
+		return 0
+	}
+
+	if r.negotiates() {
+		return r.quality(context)
+	}
+
+	return 1
 }
 
 func (r *Route) matchHeaders(headers http.Header) bool {
@@ -570,7 +791,8 @@ func (r *Route) matchQueries(query url.Values) bool {
 }
 
 func newRoute(path *Path) *Route {
-	paramKeys := patParamKeys(path.Path)
+	patterns, pathRE := pathPatterns(path)
+	paramKeys := patParamKeys(patterns[0])
 
 	method := mALL
 	if len(path.Methods) != 0 {
@@ -588,25 +810,59 @@ func newRoute(path *Path) *Route {
 		q.initQueryRoute()
 	}
 
+	vars, err := compileVars(path.Vars)
+	if err != nil {
+		panic(fmt.Sprintf("invalid vars in route '%s': %v", path.Path, err))
+	}
+
+	matchers, err := newMatchers(path.Matchers)
+	if err != nil {
+		panic(fmt.Sprintf("invalid matchers in route '%s': %v", path.Path, err))
+	}
+
 	r := &Route{
+		path:           path,
 		pattern:        path.Path,
 		backend:        path.Backend,
 		headers:        path.Headers,
 		queries:        path.Queries,
 		matchAllHeader: path.MatchAllHeader,
 		paramKeys:      paramKeys,
+		patterns:       patterns,
+		pathType:       path.PathType,
+		pathRE:         pathRE,
 		method:         method,
+		vars:           vars,
+		matchers:       matchers,
+		produces:       newMediaRanges(path.Produces),
+		consumes:       newMediaRanges(path.Consumes),
+		languages:      newLanguageTags(path.Languages),
 	}
 
 	return r
 }
 
-func (pc PathCache) addPath(path *Path) {
-	p := path.Path
-	if _, ok := pc[p]; ok {
-		pc[p] = append(pc[p], newRoute(path))
+// paramIndexes returns the position of each param of the pattern, an optional
+// variant of the route, in the route params.
+func (r *Route) paramIndexes(pattern string) []int {
+	keys := patParamKeys(pattern)
+	idx := make([]int, 0, len(keys))
+	for _, k := range keys {
+		for i, rk := range r.paramKeys {
+			if rk == k {
+				idx = append(idx, i)
+				break
+			}
+		}
+	}
+	return idx
+}
+
+func (pc PathCache) addRoute(pattern string, r *Route) {
+	if _, ok := pc[pattern]; ok {
+		pc[pattern] = pc[pattern].insertRoute(r)
 	} else {
-		pc[p] = []*Route{newRoute(path)}
+		pc[pattern] = []*Route{r}
 	}
 }
 
@@ -632,15 +888,19 @@ func newMuxRule(rule *Rule, disablePathCache bool) *muxRule {
 	}
 
 	for _, path := range rule.Paths {
+		r := newRoute(path)
+		mr.routes = append(mr.routes, r)
 
-		seg := patNextSegment(path.Path)
+		for _, pattern := range r.patterns {
+			seg := patNextSegment(pattern)
 
-		if !disablePathCache && seg.nodeType == ntStatic {
-			mr.pathCache.addPath(path)
-		} else {
-			_, err := mr.root.insert(path)
-			if err != nil {
-				panic(err)
+			if !disablePathCache && seg.nodeType == ntStatic {
+				mr.pathCache.addRoute(pattern, r)
+			} else {
+				_, err := mr.root.insert(pattern, r)
+				if err != nil {
+					panic(err)
+				}
 			}
 		}
 	}
@@ -676,6 +936,22 @@ func newContext(req *http.Request) *Context {
 	return context
 }
 
+// Request returns the request being routed.
+func (c *Context) Request() *http.Request {
+	return c.request
+}
+
+// GetParam returns the value of the route param, or an empty string if the
+// route has no such param. The key of an unnamed wildcard is *.
+func (c *Context) GetParam(key string) string {
+	for i, k := range c.routeParams.Keys {
+		if k == key && i < len(c.routeParams.Values) {
+			return c.routeParams.Values[i]
+		}
+	}
+	return ""
+}
+
 func (c *Context) GetHeaders() http.Header {
 	if c.headers != nil {
 		return c.headers
@@ -706,28 +982,53 @@ func New(rules []*Rule, disablePathCache bool) ArtRouter {
 	return router
 }
 
+// buildRouter builds a router, New panics on invalid rules.
+func buildRouter(rules []*Rule, disablePathCache bool) (ar *ArtRouter, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ar, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	router := New(rules, disablePathCache)
+	return &router, nil
+}
+
 func (ar *ArtRouter) Search(req *http.Request) *Context {
+	return ar.search(newContext(req))
+}
+
+// requestHost returns the host of the request without the port.
+func requestHost(req *http.Request) string {
 	host := req.Host
 	if h, _, err := net.SplitHostPort(host); err == nil {
 		host = h
 	}
+	return host
+}
 
-	path := req.URL.Path
-
-	context := newContext(req)
+func (ar *ArtRouter) search(context *Context) *Context {
+	host := requestHost(context.request)
+	path := context.request.URL.Path
 
 	for _, rule := range ar.rules {
 		if !rule.match(host) {
+			context.trace.rule(rule, host, false)
 			continue
 		}
+		context.trace.rule(rule, host, true)
 
 		if !rule.disablePathCache {
-			if routes, ok := rule.pathCache[path]; ok {
-				for _, route := range routes {
-					if route.match(context) {
-						context.Route = route
-						return context
+			routes, ok := rule.pathCache[path]
+			context.trace.cache(path, ok)
+			if ok {
+				if route := routes.match(context); route != nil {
+					context.Route = route
+					if len(route.paramKeys) > 0 {
+						// a static variant of a route with optional params
+						context.routeParams.Keys = append(context.routeParams.Keys, route.paramKeys...)
+						context.routeParams.Values = make([]string, len(route.paramKeys))
 					}
+					return context
 				}
 			}
 		}
@@ -285,6 +285,12 @@ func TestTree(t *testing.T) {
 
 		assert.Equal(tt.k, paramKeys)
 		assert.Equal(tt.v, paramValues)
+
+		// SearchAll finds the same route and params first
+		if all := router.SearchAll(req); context.Route != nil && assert.NotEmpty(all, tt.r) {
+			assert.Equal(context.Route, all[0].Route, tt.r)
+			assert.Equal(tt.v, all[0].routeParams.Values, tt.r)
+		}
 	}
 }
 
@@ -720,6 +726,354 @@ func TestTreeRegexMatchWholeParam(t *testing.T) {
 	}
 }
 
+func TestTreeParamTypes(t *testing.T) {
+	RegisterParamType("even", func(s string) bool {
+		return isUint(s) && (s[len(s)-1]-'0')%2 == 0
+	})
+
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{Path: "/users/{id:int}", Backend: "int"},
+				{Path: "/users/{id:uuid}", Backend: "uuid"},
+				{Path: "/users/{name:alpha}/{n:even}", Backend: "even"},
+				{Path: "/users/{name:alnum}", Backend: "alnum"},
+				{Path: "/users/{id}", Backend: "any"},
+				{Path: "/posts/{day:date}/{slug:slug}", Backend: "post"},
+				{Path: "/blobs/{sha:hex}.{n:uint}", Backend: "blob"},
+			},
+		},
+	}
+
+	tests := []struct {
+		r string   // input request path
+		h string   // output matched handler
+		k []string // output param keys
+		v []string // output param values
+	}{
+		{r: "/users/-42", h: "int", k: []string{"id"}, v: []string{"-42"}},
+		{r: "/users/0b9d2d3c-5b7a-4a55-9a43-6b2f0d8e9f10", h: "uuid", k: []string{"id"}, v: []string{"0b9d2d3c-5b7a-4a55-9a43-6b2f0d8e9f10"}},
+		{r: "/users/abc1", h: "alnum", k: []string{"name"}, v: []string{"abc1"}},
+		{r: "/users/a-b", h: "any", k: []string{"id"}, v: []string{"a-b"}},
+		{r: "/users/bob/12", h: "even", k: []string{"name", "n"}, v: []string{"bob", "12"}},
+		{r: "/users/bob/13", h: "", k: nil, v: []string{}},
+		{r: "/posts/2023-02-28/hello-world", h: "post", k: []string{"day", "slug"}, v: []string{"2023-02-28", "hello-world"}},
+		{r: "/posts/2023-13-28/hello-world", h: "", k: nil, v: nil},
+		{r: "/posts/2023-02-28/hello--world", h: "", k: nil, v: []string{}},
+		{r: "/blobs/deadBEEF.7", h: "blob", k: []string{"sha", "n"}, v: []string{"deadBEEF", "7"}},
+		{r: "/blobs/xyz.7", h: "", k: nil, v: nil},
+	}
+
+	router := New(rules, false)
+	assert := assert.New(t)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.r, nil)
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(tt.h, backend, tt.r)
+
+		paramKeys := context.routeParams.Keys
+		paramValues := context.routeParams.Values
+
+		assert.Equal(tt.k, paramKeys, tt.r)
+		assert.Equal(tt.v, paramValues, tt.r)
+	}
+
+	assert.Panics(func() { RegisterParamType("int", isInt) })
+}
+
+func TestTreeOptional(t *testing.T) {
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{Path: "/users[/{id}]", Backend: "users"},
+				{Path: "/posts/{id?:int}", Backend: "posts"},
+				{Path: "[/v{version}]/items/{item}", Backend: "items"},
+				{Path: "/files[/{dir}[/{name:[a-z]+}]]/raw", Backend: "files"},
+				{Path: "/docs[/*]", Backend: "docs"},
+			},
+		},
+	}
+
+	tests := []struct {
+		r string   // input request path
+		h string   // output matched handler
+		k []string // output param keys
+		v []string // output param values
+	}{
+		{r: "/users", h: "users", k: []string{"id"}, v: []string{""}},
+		{r: "/users/1", h: "users", k: []string{"id"}, v: []string{"1"}},
+		{r: "/posts", h: "posts", k: []string{"id"}, v: []string{""}},
+		{r: "/posts/12", h: "posts", k: []string{"id"}, v: []string{"12"}},
+		{r: "/posts/abc", h: "", k: nil, v: nil},
+		{r: "/items/a", h: "items", k: []string{"version", "item"}, v: []string{"", "a"}},
+		{r: "/v2/items/a", h: "items", k: []string{"version", "item"}, v: []string{"2", "a"}},
+		{r: "/files/raw", h: "files", k: []string{"dir", "name"}, v: []string{"", ""}},
+		{r: "/files/tmp/raw", h: "files", k: []string{"dir", "name"}, v: []string{"tmp", ""}},
+		{r: "/files/tmp/abc/raw", h: "files", k: []string{"dir", "name"}, v: []string{"tmp", "abc"}},
+		{r: "/docs", h: "docs", k: []string{"*"}, v: []string{""}},
+		{r: "/docs/a/b", h: "docs", k: []string{"*"}, v: []string{"a/b"}},
+	}
+
+	for _, disablePathCache := range []bool{false, true} {
+		router := New(rules, disablePathCache)
+		assert := assert.New(t)
+
+		for _, tt := range tests {
+			req, _ := http.NewRequest(http.MethodGet, tt.r, nil)
+			context := router.Search(req)
+
+			var backend string
+
+			if context.Route != nil {
+				backend = context.Route.backend
+			}
+
+			assert.Equal(tt.h, backend, tt.r)
+
+			paramKeys := context.routeParams.Keys
+			paramValues := context.routeParams.Values
+
+			assert.Equal(tt.k, paramKeys, tt.r)
+			assert.Equal(tt.v, paramValues, tt.r)
+		}
+	}
+}
+
+func TestExpandPattern(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"/users"}, expandPattern("/users"))
+	assert.Equal([]string{"/users/{id}", "/users"}, expandPattern("/users/{id?}"))
+	assert.Equal([]string{"/v{version}/x", "/v/x"}, expandPattern("/v{version?}/x"))
+	assert.Equal([]string{"/a/{id:[0-9]+}", "/a"}, expandPattern("/a[/{id:[0-9]+}]"))
+	assert.Equal([]string{"/"}, expandPattern("[/]"))
+	assert.Panics(func() { expandPattern("/a[/b") })
+	assert.Panics(func() { expandPattern("/a]/b") })
+}
+
+func TestTreeMultiParam(t *testing.T) {
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{Path: "/repos/{owner}/{file...}/raw", Backend: "raw"},
+				{Path: "/repos/{owner}/{file...}/blame/{line}", Backend: "blame"},
+				{Path: "/repos/{owner}/{file...}.{ext}", Backend: "ext"},
+				{Path: "/tree/{path...}", Backend: "tree"},
+			},
+		},
+	}
+
+	tests := []struct {
+		r string   // input request path
+		h string   // output matched handler
+		k []string // output param keys
+		v []string // output param values
+	}{
+		{r: "/repos/go/src/net/http/raw", h: "raw", k: []string{"owner", "file"}, v: []string{"go", "src/net/http"}},
+		{r: "/repos/go/a/raw/b/raw", h: "raw", k: []string{"owner", "file"}, v: []string{"go", "a/raw/b"}},
+		{r: "/repos/go/raw", h: "", k: nil, v: []string{}},
+		{r: "/repos/go/a/b/blame/12", h: "blame", k: []string{"owner", "file", "line"}, v: []string{"go", "a/b", "12"}},
+		{r: "/repos/go/a/b.tar.gz", h: "ext", k: []string{"owner", "file", "ext"}, v: []string{"go", "a/b", "tar.gz"}},
+		{r: "/tree/a", h: "tree", k: []string{"path"}, v: []string{"a"}},
+		{r: "/tree/a/b/", h: "tree", k: []string{"path"}, v: []string{"a/b/"}},
+	}
+
+	router := New(rules, false)
+	assert := assert.New(t)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.r, nil)
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(tt.h, backend, tt.r)
+
+		paramKeys := context.routeParams.Keys
+		paramValues := context.routeParams.Values
+
+		assert.Equal(tt.k, paramKeys, tt.r)
+		assert.Equal(tt.v, paramValues, tt.r)
+	}
+}
+
+func TestTreeNamedWildcard(t *testing.T) {
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{Path: "/static/{rest*}", Methods: []string{"POST"}, Backend: "upload"},
+				{Path: "/static/*", Backend: "static"},
+				{Path: "/assets/*.js", Backend: "js"},
+				{Path: "/assets/*.css", Backend: "css"},
+				{Path: "/img/*.{ext:png|jpg}", Backend: "img"},
+				{Path: "/img/{name*}/thumb", Backend: "thumb"},
+				{Path: "/assets/*", Backend: "assets"},
+			},
+		},
+	}
+
+	tests := []struct {
+		m string
+		r string   // input request path
+		h string   // output matched handler
+		k []string // output param keys
+		v []string // output param values
+	}{
+		{m: "GET", r: "/static/css/site.css", h: "static", k: []string{"*"}, v: []string{"css/site.css"}},
+		{m: "POST", r: "/static/a", h: "upload", k: []string{"rest"}, v: []string{"a"}},
+		{m: "GET", r: "/assets/app.min.js", h: "js", k: []string{"*"}, v: []string{"app.min"}},
+		{m: "GET", r: "/assets/lib/app.js", h: "js", k: []string{"*"}, v: []string{"lib/app"}},
+		{m: "GET", r: "/assets/lib/app.css", h: "css", k: []string{"*"}, v: []string{"lib/app"}},
+		{m: "GET", r: "/assets/lib/app.ts", h: "assets", k: []string{"*"}, v: []string{"lib/app.ts"}},
+		{m: "GET", r: "/img/a/b.c.png", h: "img", k: []string{"*", "ext"}, v: []string{"a/b.c", "png"}},
+		{m: "GET", r: "/img/a.gif", h: "", k: nil, v: []string{}},
+		{m: "GET", r: "/img/a.pngx", h: "", k: nil, v: []string{}},
+		{m: "GET", r: "/img/a.xjpg", h: "", k: nil, v: []string{}},
+		{m: "GET", r: "/img/a/b/thumb", h: "thumb", k: []string{"name"}, v: []string{"a/b"}},
+	}
+
+	router := New(rules, false)
+	assert := assert.New(t)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, tt.r, nil)
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(tt.h, backend, tt.r)
+
+		paramKeys := context.routeParams.Keys
+		paramValues := context.routeParams.Values
+
+		assert.Equal(tt.k, paramKeys, tt.r)
+		assert.Equal(tt.v, paramValues, tt.r)
+
+		for i, k := range tt.k {
+			assert.Equal(tt.v[i], context.GetParam(k))
+		}
+	}
+}
+
+func TestTreePathType(t *testing.T) {
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{Path: "/foo/", PathType: PathTypePrefix, Backend: "foo"},
+				{Path: "/foo", PathType: PathTypeExact, Backend: "foo-exact"},
+				{Path: "/foo/bar", PathType: PathTypePrefix, Backend: "foo-bar"},
+				{Path: "/api/v[0-9]+/(users|groups)", PathType: PathTypeRegularExpression, Backend: "api"},
+				{Path: "/{x}", PathType: PathTypeImplementationSpecific, Backend: "pattern"},
+				{Path: "/", PathType: PathTypePrefix, Backend: "root"},
+			},
+		},
+	}
+
+	tests := []struct {
+		r string // input request path
+		h string // output matched handler
+	}{
+		{r: "/foo", h: "foo-exact"},
+		{r: "/foo/", h: "foo"},
+		{r: "/foo/baz", h: "foo"},
+		{r: "/foo/bar", h: "foo-bar"},
+		{r: "/foo/bar/", h: "foo-bar"},
+		{r: "/foo/barbaz", h: "foo"},
+		{r: "/foobar", h: "pattern"},
+		{r: "/api/v2/users", h: "api"},
+		{r: "/api/v2/users/1", h: "root"},
+		{r: "/api/vx/users", h: "root"},
+		{r: "/a/b", h: "root"},
+		{r: "/", h: "root"},
+	}
+
+	for _, disablePathCache := range []bool{false, true} {
+		router := New(rules, disablePathCache)
+		assert := assert.New(t)
+
+		for _, tt := range tests {
+			req, _ := http.NewRequest(http.MethodGet, tt.r, nil)
+			context := router.Search(req)
+
+			var backend string
+
+			if context.Route != nil {
+				backend = context.Route.backend
+			}
+
+			assert.Equal(tt.h, backend, tt.r)
+		}
+	}
+
+	assert.Panics(t, func() { New([]*Rule{{Paths: []*Path{{Path: "/{x}", PathType: PathTypeExact}}}}, false) })
+	assert.Panics(t, func() { New([]*Rule{{Paths: []*Path{{Path: "/(", PathType: PathTypeRegularExpression}}}}, false) })
+	assert.Panics(t, func() { New([]*Rule{{Paths: []*Path{{Path: "/", PathType: "Fuzzy"}}}}, false) })
+}
+
+func TestTreeRegexpPathType(t *testing.T) {
+	rules := []*Rule{
+		{
+			Paths: []*Path{
+				{Path: "/api/(a|b)/.*", PathType: PathTypeRegularExpression, Backend: "ab"},
+				{Path: "/api/[cd]/.*", PathType: PathTypeRegularExpression, Backend: "cd"},
+				{Path: "/api/{rest*}", Backend: "rest"},
+				{Path: "/api/(e|f)/.*", PathType: PathTypeRegularExpression, Backend: "ef"},
+				{Path: "/api/{x}/{y}/{z}", Backend: "params"},
+				{Path: "/", PathType: PathTypePrefix, Backend: "root"},
+			},
+		},
+	}
+
+	tests := []struct {
+		r string // input request path
+		h string // output matched handler
+	}{
+		{r: "/api/a/x", h: "ab"},
+		{r: "/api/d/x", h: "cd"},
+		// the regexps share the catch-all of /api/, a path they reject falls
+		// through to the next routes of the catch-all
+		{r: "/api/g/x", h: "rest"},
+		// a later regexp competes with the earlier catch-all in order
+		{r: "/api/e/x", h: "rest"},
+		// the params below the prefix are matched before the catch-all
+		{r: "/api/a/x/y", h: "params"},
+		{r: "/apix", h: "root"},
+	}
+
+	for _, disablePathCache := range []bool{false, true} {
+		router := New(rules, disablePathCache)
+
+		for _, tt := range tests {
+			req, _ := http.NewRequest(http.MethodGet, tt.r, nil)
+			context := router.Search(req)
+
+			var backend string
+
+			if context.Route != nil {
+				backend = context.Route.backend
+			}
+
+			assert.Equal(t, tt.h, backend, tt.r)
+		}
+	}
+}
+
 func BenchmarkTreeGet(b *testing.B) {
 	h1 := "h1"
 	h2 := "h2"
@@ -0,0 +1,571 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule expressions use the syntax of the Traefik routing rules, e.g.
+//
+//	Host(`a.com`) && PathPrefix(`/api`) && Method(`GET`, `POST`) && Headers(`X-Env`, `prod`)
+//
+// Matchers are combined with &&, || and !, parentheses group expressions.
+// Arguments are quoted with backticks or double quotes.
+
+type (
+	// RuleExpr is a parsed rule expression, String returns its canonical form.
+	RuleExpr interface {
+		String() string
+	}
+
+	// RuleMatcher is a single matcher like Host(`a.com`).
+	RuleMatcher struct {
+		Name string
+		Args []string
+		// Pos is the byte offset of the matcher in the rule string
+		Pos int
+		// ArgPos are the byte offsets of the arguments
+		ArgPos []int
+	}
+
+	// RuleAnd is the conjunction of expressions.
+	RuleAnd []RuleExpr
+
+	// RuleOr is the disjunction of expressions.
+	RuleOr []RuleExpr
+
+	// RuleNot is the negation of an expression.
+	RuleNot struct {
+		X RuleExpr
+	}
+
+	// RuleSyntaxError reports an invalid rule string.
+	RuleSyntaxError struct {
+		Msg string
+		// Pos is the byte offset of the error in the rule string
+		Pos int
+	}
+
+	ruleToken struct {
+		val string
+		pos int
+		typ ruleTokenType
+	}
+
+	ruleTokenType uint8
+
+	ruleParser struct {
+		tokens []ruleToken
+		idx    int
+	}
+)
+
+const (
+	rtEOF ruleTokenType = iota
+	rtIdent
+	rtString
+	rtLParen
+	rtRParen
+	rtComma
+	rtAnd
+	rtOr
+	rtNot
+)
+
+// ruleMatchers maps the supported matchers to their min and max argument count,
+// -1 means no limit.
+var ruleMatchers = map[string][2]int{
+	"Host":          {1, -1},
+	"HostRegexp":    {1, 1},
+	"Path":          {1, 1},
+	"PathPrefix":    {1, 1},
+	"PathRegexp":    {1, 1},
+	"Method":        {1, -1},
+	"Headers":       {2, 2},
+	"Header":        {2, 2},
+	"HeadersRegexp": {2, 2},
+	"HeaderRegexp":  {2, 2},
+	"Query":         {1, 2},
+	"QueryRegexp":   {2, 2},
+}
+
+func (e *RuleSyntaxError) Error() string {
+	return fmt.Sprintf("rule syntax error at column %d: %s", e.Pos+1, e.Msg)
+}
+
+func (m *RuleMatcher) String() string {
+	var sb strings.Builder
+	sb.WriteString(m.Name)
+	sb.WriteByte('(')
+	for i, arg := range m.Args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if strings.IndexByte(arg, '`') >= 0 {
+			sb.WriteString(strconv.Quote(arg))
+		} else {
+			sb.WriteByte('`')
+			sb.WriteString(arg)
+			sb.WriteByte('`')
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+func (e RuleAnd) String() string {
+	parts := make([]string, 0, len(e))
+	for _, x := range e {
+		if _, ok := x.(RuleOr); ok {
+			parts = append(parts, "("+x.String()+")")
+		} else {
+			parts = append(parts, x.String())
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+func (e RuleOr) String() string {
+	parts := make([]string, 0, len(e))
+	for _, x := range e {
+		parts = append(parts, x.String())
+	}
+	return strings.Join(parts, " || ")
+}
+
+func (e *RuleNot) String() string {
+	switch e.X.(type) {
+	case RuleAnd, RuleOr:
+		return "!(" + e.X.String() + ")"
+	}
+	return "!" + e.X.String()
+}
+
+// ParseRuleExpr parses a rule string, syntax errors are reported as *RuleSyntaxError.
+func ParseRuleExpr(rule string) (RuleExpr, error) {
+	tokens, err := lexRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ruleParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.typ != rtEOF {
+		return nil, &RuleSyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected '%s'", tok.val)}
+	}
+
+	return e, nil
+}
+
+// ParseRuleString parses a rule string and converts it into rules routing to backend.
+func ParseRuleString(rule, backend string) ([]*Rule, error) {
+	e, err := ParseRuleExpr(rule)
+	if err != nil {
+		return nil, err
+	}
+	return RulesFromExpr(e, backend)
+}
+
+func lexRule(s string) ([]ruleToken, error) {
+	tokens := []ruleToken{}
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, ruleToken{typ: rtLParen, val: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, ruleToken{typ: rtRParen, val: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, ruleToken{typ: rtComma, val: ",", pos: i})
+			i++
+		case c == '!':
+			tokens = append(tokens, ruleToken{typ: rtNot, val: "!", pos: i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(s) || s[i+1] != c {
+				return nil, &RuleSyntaxError{Pos: i, Msg: fmt.Sprintf("expected '%c%c'", c, c)}
+			}
+			typ := rtAnd
+			if c == '|' {
+				typ = rtOr
+			}
+			tokens = append(tokens, ruleToken{typ: typ, val: s[i : i+2], pos: i})
+			i += 2
+		case c == '`':
+			end := strings.IndexByte(s[i+1:], '`')
+			if end < 0 {
+				return nil, &RuleSyntaxError{Pos: i, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, ruleToken{typ: rtString, val: s[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, &RuleSyntaxError{Pos: i, Msg: "unterminated string"}
+			}
+			val, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, &RuleSyntaxError{Pos: i, Msg: "invalid string"}
+			}
+			tokens = append(tokens, ruleToken{typ: rtString, val: val, pos: i})
+			i = j + 1
+		case isIdentByte(c):
+			j := i
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			tokens = append(tokens, ruleToken{typ: rtIdent, val: s[i:j], pos: i})
+			i = j
+		default:
+			return nil, &RuleSyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character '%c'", c)}
+		}
+	}
+	tokens = append(tokens, ruleToken{typ: rtEOF, val: "end of rule", pos: len(s)})
+	return tokens, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.idx]
+}
+
+func (p *ruleParser) next() ruleToken {
+	tok := p.tokens[p.idx]
+	if tok.typ != rtEOF {
+		p.idx++
+	}
+	return tok
+}
+
+func (p *ruleParser) expect(typ ruleTokenType, what string) (ruleToken, error) {
+	tok := p.next()
+	if tok.typ != typ {
+		return tok, &RuleSyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected %s, got '%s'", what, tok.val)}
+	}
+	return tok, nil
+}
+
+func (p *ruleParser) parseOr() (RuleExpr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	var or RuleOr
+	for p.peek().typ == rtOr {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if or == nil {
+			or = RuleOr{x}
+		}
+		or = append(or, y)
+	}
+
+	if or != nil {
+		return or, nil
+	}
+	return x, nil
+}
+
+func (p *ruleParser) parseAnd() (RuleExpr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	var and RuleAnd
+	for p.peek().typ == rtAnd {
+		p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if and == nil {
+			and = RuleAnd{x}
+		}
+		and = append(and, y)
+	}
+
+	if and != nil {
+		return and, nil
+	}
+	return x, nil
+}
+
+func (p *ruleParser) parseUnary() (RuleExpr, error) {
+	tok := p.next()
+	switch tok.typ {
+	case rtNot:
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &RuleNot{X: x}, nil
+
+	case rtLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(rtRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return x, nil
+
+	case rtIdent:
+		return p.parseMatcher(tok)
+	}
+
+	return nil, &RuleSyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected matcher, got '%s'", tok.val)}
+}
+
+func (p *ruleParser) parseMatcher(name ruleToken) (RuleExpr, error) {
+	arity, ok := ruleMatchers[name.val]
+	if !ok {
+		return nil, &RuleSyntaxError{Pos: name.pos, Msg: fmt.Sprintf("unknown matcher '%s'", name.val)}
+	}
+
+	if _, err := p.expect(rtLParen, "'('"); err != nil {
+		return nil, err
+	}
+
+	m := &RuleMatcher{Name: name.val, Pos: name.pos}
+	for {
+		arg, err := p.expect(rtString, "string")
+		if err != nil {
+			return nil, err
+		}
+		m.Args = append(m.Args, arg.val)
+		m.ArgPos = append(m.ArgPos, arg.pos)
+
+		tok := p.next()
+		if tok.typ == rtRParen {
+			break
+		}
+		if tok.typ != rtComma {
+			return nil, &RuleSyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected ',' or ')', got '%s'", tok.val)}
+		}
+	}
+
+	if len(m.Args) < arity[0] || (arity[1] >= 0 && len(m.Args) > arity[1]) {
+		return nil, &RuleSyntaxError{Pos: name.pos, Msg: fmt.Sprintf("wrong number of arguments for %s", m.Name)}
+	}
+
+	if m.Name == "Method" {
+		for i, method := range m.Args {
+			method = strings.ToUpper(method)
+			if _, ok := methodMap[method]; !ok {
+				return nil, &RuleSyntaxError{Pos: m.ArgPos[i], Msg: fmt.Sprintf("unknown method '%s'", method)}
+			}
+			m.Args[i] = method
+		}
+	}
+
+	if m.Name == "Query" && len(m.Args) == 1 {
+		kv := strings.SplitN(m.Args[0], "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, &RuleSyntaxError{Pos: m.ArgPos[0], Msg: "Query expects `key=value` or `key`, `value`"}
+		}
+		m.Args = kv
+	}
+
+	return m, nil
+}
+
+// RulesFromExpr converts a rule expression into rules routing to backend. The
+// top-level Host, HostRegexp, Path, PathPrefix, Method, header and query
+// matchers are mapped onto the Rule and Path fields, everything else is
+// expressed as Path.Vars.
+func RulesFromExpr(e RuleExpr, backend string) ([]*Rule, error) {
+	var hosts []string
+	var vars []interface{}
+
+	rule := &Rule{}
+	path := &Path{Backend: backend}
+
+	for _, x := range flattenRuleAnd(e, nil) {
+		if m, ok := x.(*RuleMatcher); ok {
+			switch m.Name {
+			case "Host":
+				if hosts == nil && rule.HostRegexp == "" {
+					hosts = m.Args
+					continue
+				}
+			case "HostRegexp":
+				if hosts == nil && rule.HostRegexp == "" {
+					if _, err := regexp.Compile(m.Args[0]); err != nil {
+						return nil, &RuleSyntaxError{Pos: m.ArgPos[0], Msg: err.Error()}
+					}
+					rule.HostRegexp = m.Args[0]
+					continue
+				}
+			case "Path":
+				if path.Path == "" {
+					path.Path = m.Args[0]
+					continue
+				}
+			case "PathPrefix":
+				if path.Path == "" {
+					path.Path = m.Args[0] + "*"
+					continue
+				}
+			case "Method":
+				if path.Methods == nil {
+					path.Methods = m.Args
+					continue
+				}
+			case "Headers", "Header":
+				path.Headers = append(path.Headers, &Header{Key: m.Args[0], Values: []string{m.Args[1]}})
+				continue
+			case "HeadersRegexp", "HeaderRegexp":
+				if _, err := regexp.Compile(m.Args[1]); err != nil {
+					return nil, &RuleSyntaxError{Pos: m.ArgPos[1], Msg: err.Error()}
+				}
+				path.Headers = append(path.Headers, &Header{Key: m.Args[0], Regexp: m.Args[1]})
+				continue
+			case "Query":
+				path.Queries = append(path.Queries, &Query{Key: m.Args[0], Values: []string{m.Args[1]}})
+				continue
+			case "QueryRegexp":
+				if _, err := regexp.Compile(m.Args[1]); err != nil {
+					return nil, &RuleSyntaxError{Pos: m.ArgPos[1], Msg: err.Error()}
+				}
+				path.Queries = append(path.Queries, &Query{Key: m.Args[0], Regexp: m.Args[1]})
+				continue
+			}
+		}
+
+		v, err := ruleExprVars(x)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+
+	if path.Path == "" {
+		path.Path = "/*"
+	}
+	path.MatchAllHeader = len(path.Headers) > 0
+	path.Vars = vars
+
+	if len(hosts) == 0 {
+		rule.Paths = []*Path{path}
+		return []*Rule{rule}, nil
+	}
+
+	rules := make([]*Rule, 0, len(hosts))
+	for _, host := range hosts {
+		rules = append(rules, &Rule{Host: host, Paths: []*Path{path}})
+	}
+	return rules, nil
+}
+
+func flattenRuleAnd(e RuleExpr, res []RuleExpr) []RuleExpr {
+	if and, ok := e.(RuleAnd); ok {
+		for _, x := range and {
+			res = flattenRuleAnd(x, res)
+		}
+		return res
+	}
+	return append(res, e)
+}
+
+// ruleExprVars converts a rule expression into a vars expression.
+func ruleExprVars(e RuleExpr) ([]interface{}, error) {
+	switch x := e.(type) {
+	case RuleAnd, RuleOr:
+		head := "AND"
+		var subs []RuleExpr
+		if and, ok := x.(RuleAnd); ok {
+			subs = and
+		} else {
+			head, subs = "OR", x.(RuleOr)
+		}
+		res := []interface{}{head}
+		for _, sub := range subs {
+			v, err := ruleExprVars(sub)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+
+	case *RuleNot:
+		v, err := ruleExprVars(x.X)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{"NOT", v}, nil
+
+	case *RuleMatcher:
+		var v []interface{}
+		switch x.Name {
+		case "Host":
+			v = []interface{}{"host", "in", stringsToInterfaces(x.Args)}
+		case "HostRegexp":
+			v = []interface{}{"host", "~~", x.Args[0]}
+		case "Path":
+			if strings.ContainsAny(x.Args[0], "{*") {
+				return nil, &RuleSyntaxError{Pos: x.ArgPos[0], Msg: "Path with params must be a top-level condition"}
+			}
+			v = []interface{}{"uri", "==", x.Args[0]}
+		case "PathPrefix":
+			v = []interface{}{"uri", "~~", "^" + regexp.QuoteMeta(x.Args[0])}
+		case "PathRegexp":
+			v = []interface{}{"uri", "~~", x.Args[0]}
+		case "Method":
+			v = []interface{}{"request_method", "in", stringsToInterfaces(x.Args)}
+		case "Headers", "Header":
+			v = []interface{}{headerVar(x.Args[0]), "==", x.Args[1]}
+		case "HeadersRegexp", "HeaderRegexp":
+			v = []interface{}{headerVar(x.Args[0]), "~~", x.Args[1]}
+		case "Query":
+			v = []interface{}{"arg_" + x.Args[0], "==", x.Args[1]}
+		case "QueryRegexp":
+			v = []interface{}{"arg_" + x.Args[0], "~~", x.Args[1]}
+		}
+		if _, err := compileExpr(v); err != nil {
+			return nil, &RuleSyntaxError{Pos: x.Pos, Msg: err.Error()}
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unknown rule expression %T", e)
+}
+
+func headerVar(key string) string {
+	return "http_" + strings.ReplaceAll(strings.ToLower(http.CanonicalHeaderKey(key)), "-", "_")
+}
+
+func stringsToInterfaces(ss []string) []interface{} {
+	res := make([]interface{}, 0, len(ss))
+	for _, s := range ss {
+		res = append(res, s)
+	}
+	return res
+}
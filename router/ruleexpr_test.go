@@ -0,0 +1,135 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRuleExpr(t *testing.T) {
+	tests := []struct {
+		rule      string
+		canonical string
+	}{
+		{
+			rule:      "Host(`a.com`) && PathPrefix(`/api`) && Method(`GET`,`POST`) && Headers(`X-Env`,`prod`)",
+			canonical: "Host(`a.com`) && PathPrefix(`/api`) && Method(`GET`, `POST`) && Headers(`X-Env`, `prod`)",
+		},
+		{
+			rule:      `(Header("X", "a") || Query("y=b")) && !Header("Z","1")`,
+			canonical: "(Header(`X`, `a`) || Query(`y`, `b`)) && !Header(`Z`, `1`)",
+		},
+		{
+			rule:      "!(Path(`/a`) && Method(`get`)) || Path(`/b`)",
+			canonical: "!(Path(`/a`) && Method(`GET`)) || Path(`/b`)",
+		},
+		{
+			rule:      "Path(\"/a`b\")",
+			canonical: "Path(\"/a`b\")",
+		},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseRuleExpr(tt.rule)
+		assert.Nil(t, err, tt.rule)
+		assert.Equal(t, tt.canonical, e.String())
+
+		again, err := ParseRuleExpr(e.String())
+		assert.Nil(t, err, tt.rule)
+		assert.Equal(t, tt.canonical, again.String())
+	}
+}
+
+func TestParseRuleExprError(t *testing.T) {
+	tests := []struct {
+		rule string
+		pos  int
+		err  string
+	}{
+		{rule: "Host(`a.com`) & Path(`/`)", pos: 14},
+		{rule: "Host(`a.com`", pos: 12},
+		{rule: "Hots(`a.com`)", pos: 0},
+		{rule: "Host(`a.com`) && ", pos: 17},
+		{rule: "Path(`/a`) Path(`/b`)", pos: 11},
+		{rule: "Method(`GET`, `FETCH`)", pos: 14, err: "rule syntax error at column 15: unknown method 'FETCH'"},
+		{rule: "Path(`/a`) && Method(`fetch`)", pos: 21, err: "rule syntax error at column 22: unknown method 'FETCH'"},
+		{rule: "Query(`novalue`)", pos: 6, err: "rule syntax error at column 7: Query expects `key=value` or `key`, `value`"},
+		{rule: "Path(`/a`, `/b`)", pos: 0},
+		{rule: "Path(`/a)", pos: 5},
+		{rule: "(Path(`/a`)", pos: 11},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseRuleExpr(tt.rule)
+		if assert.IsType(t, &RuleSyntaxError{}, err, tt.rule) {
+			assert.Equal(t, tt.pos, err.(*RuleSyntaxError).Pos, tt.rule)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err, tt.rule)
+			}
+		}
+	}
+
+	// the errors of RulesFromExpr are at the argument at fault
+	_, err := ParseRuleString("Path(`/a`) || Path(`/b/{id}`)", "b")
+	if assert.IsType(t, &RuleSyntaxError{}, err) {
+		assert.Equal(t, 19, err.(*RuleSyntaxError).Pos)
+	}
+	_, err = ParseRuleString("Host(`a.com`) && HeaderRegexp(`X`, `(`)", "b")
+	if assert.IsType(t, &RuleSyntaxError{}, err) {
+		assert.Equal(t, 35, err.(*RuleSyntaxError).Pos)
+	}
+}
+
+func TestParseRuleString(t *testing.T) {
+	rules, err := ParseRuleString("Host(`a.com`, `b.com`) && PathPrefix(`/api`) && Method(`GET`) && Headers(`X-Env`, `prod`)", "api")
+	assert.Nil(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "a.com", rules[0].Host)
+	assert.Equal(t, "b.com", rules[1].Host)
+	assert.Equal(t, "/api*", rules[0].Paths[0].Path)
+	assert.Equal(t, []string{"GET"}, rules[0].Paths[0].Methods)
+	assert.True(t, rules[0].Paths[0].MatchAllHeader)
+
+	more, err := ParseRuleString("Path(`/users/{id}`) && (Header(`X`, `a`) || Query(`y`, `b`)) && !Method(`DELETE`)", "users")
+	assert.Nil(t, err)
+	assert.Equal(t, "/users/{id}", more[0].Paths[0].Path)
+	assert.Len(t, more[0].Paths[0].Vars, 2)
+
+	rules = append(rules, more...)
+
+	tests := []struct {
+		m       string
+		host    string
+		r       string
+		h       string
+		headers map[string]string
+	}{
+		{m: "GET", host: "a.com", r: "/api/x", h: "api", headers: map[string]string{"X-Env": "prod"}},
+		{m: "GET", host: "b.com", r: "/api", h: "api", headers: map[string]string{"X-Env": "prod"}},
+		{m: "GET", host: "a.com", r: "/api/x", h: "", headers: map[string]string{"X-Env": "dev"}},
+		{m: "POST", host: "a.com", r: "/api/x", h: "", headers: map[string]string{"X-Env": "prod"}},
+		{m: "GET", host: "c.com", r: "/users/1", h: "users", headers: map[string]string{"X": "a"}},
+		{m: "GET", host: "c.com", r: "/users/1?y=b", h: "users"},
+		{m: "DELETE", host: "c.com", r: "/users/1?y=b", h: ""},
+		{m: "GET", host: "c.com", r: "/users/1", h: ""},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.m, "http://"+tt.host+tt.r, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s%s %v", tt.m, tt.host, tt.r, tt.headers)
+	}
+}
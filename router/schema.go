@@ -0,0 +1,143 @@
+package router
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// schemaTag holds the constraints of a jsonschema struct tag, e.g.
+// `jsonschema:"required,pattern=^/"`.
+type schemaTag struct {
+	pattern     string
+	format      string
+	enum        []string
+	required    bool
+	uniqueItems bool
+}
+
+func parseSchemaTag(tag string) schemaTag {
+	st := schemaTag{}
+	for _, c := range strings.Split(tag, ",") {
+		key, value := c, ""
+		if i := strings.IndexByte(c, '='); i >= 0 {
+			key, value = c[:i], c[i+1:]
+		}
+		switch key {
+		case "required":
+			st.required = true
+		case "pattern":
+			st.pattern = value
+		case "format":
+			st.format = value
+		case "uniqueItems":
+			st.uniqueItems = value == "true"
+		case "enum":
+			st.enum = append(st.enum, value)
+		}
+	}
+	return st
+}
+
+// jsonFieldName returns the JSON name of an exported field, or an empty string
+// for a field left out of JSON.
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if f.PkgPath != "" || name == "-" {
+		return ""
+	}
+	return name
+}
+
+// JSONSchema returns a JSON Schema (draft-07) of a config, a list of rules or
+// a Config, generated from the jsonschema tags of Config and the types below it.
+// Like the config loader, it takes null for an empty list and for no other type.
+func JSONSchema() ([]byte, error) {
+	defs := map[string]interface{}{}
+	schema := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "art-router rules",
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "array", "items": typeSchema(reflect.TypeOf(Rule{}), defs)},
+			typeSchema(reflect.TypeOf(Config{}), defs),
+		},
+		"definitions": defs,
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// typeSchema returns the schema of a type, structs are added to defs and
+// referenced.
+func typeSchema(typ reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == rawMessageType || typ.Kind() == reflect.Interface:
+		return map[string]interface{}{}
+	case typ.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case typ.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case typ.Kind() == reflect.Int:
+		return map[string]interface{}{"type": "integer"}
+	case typ.Kind() == reflect.Slice:
+		// the config loader takes null as an empty list
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": typeSchema(typ.Elem(), defs)}
+	case typ.Kind() != reflect.Struct:
+		panic("BUG: no schema for type " + typ.String())
+	}
+
+	ref := map[string]interface{}{"$ref": "#/definitions/" + typ.Name()}
+	if _, ok := defs[typ.Name()]; ok {
+		return ref
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	// the definition is added first for recursive types
+	def := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	defs[typ.Name()] = def
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := jsonFieldName(f)
+		if name == "" {
+			continue
+		}
+
+		tag := parseSchemaTag(f.Tag.Get("jsonschema"))
+		s := typeSchema(f.Type, defs)
+
+		if tag.pattern != "" {
+			s["pattern"] = tag.pattern
+		}
+		switch tag.format {
+		case "regexp":
+			s["format"] = "regex"
+		case "httpmethod-array":
+			s["items"] = map[string]interface{}{"type": "string", "enum": allMethods()}
+		}
+		if tag.uniqueItems {
+			s["uniqueItems"] = true
+		}
+		if len(tag.enum) > 0 {
+			s["enum"] = tag.enum
+		}
+		if tag.required {
+			required = append(required, name)
+		}
+
+		properties[name] = s
+	}
+
+	if len(required) > 0 {
+		def["required"] = required
+	}
+	return ref
+}
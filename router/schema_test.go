@@ -0,0 +1,191 @@
+package router
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "update rules.schema.json")
+
+// TestJSONSchemaInSync keeps rules.schema.json in sync with the spec types, run
+// go test -run TestJSONSchemaInSync -update-schema after changing them.
+func TestJSONSchemaInSync(t *testing.T) {
+	schema, err := JSONSchema()
+	assert.Nil(t, err)
+	schema = append(schema, '\n')
+
+	if *updateSchema {
+		assert.Nil(t, os.WriteFile("rules.schema.json", schema, 0o644))
+	}
+
+	data, err := os.ReadFile("rules.schema.json")
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), string(schema), "rules.schema.json is out of date, run go test -run TestJSONSchemaInSync -update-schema")
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema()
+	assert.Nil(t, err)
+
+	var schema struct {
+		OneOf []struct {
+			Type  string            `json:"type"`
+			Items map[string]string `json:"items"`
+			Ref   string            `json:"$ref"`
+		} `json:"oneOf"`
+		Definitions map[string]struct {
+			Properties           map[string]map[string]interface{} `json:"properties"`
+			Required             []string                          `json:"required"`
+			AdditionalProperties bool                              `json:"additionalProperties"`
+		} `json:"definitions"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &schema))
+
+	assert.Len(t, schema.OneOf, 2)
+	assert.Equal(t, "array", schema.OneOf[0].Type)
+	assert.Equal(t, "#/definitions/Rule", schema.OneOf[0].Items["$ref"])
+	assert.Equal(t, "#/definitions/Config", schema.OneOf[1].Ref)
+	assert.Len(t, schema.Definitions, 6)
+
+	path := schema.Definitions["Path"]
+	assert.Equal(t, []string{"backend"}, path.Required)
+	assert.False(t, path.AdditionalProperties)
+	assert.Equal(t, "^/", path.Properties["path"]["pattern"])
+	assert.Equal(t, true, path.Properties["methods"]["uniqueItems"])
+	assert.Contains(t, path.Properties["methods"]["items"].(map[string]interface{})["enum"], "GET")
+	assert.Equal(t, []interface{}{"Exact", "Prefix", "RegularExpression", "ServeMux", "ImplementationSpecific"}, path.Properties["pathType"]["enum"])
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"array", "null"}, "items": map[string]interface{}{"$ref": "#/definitions/Header"}}, path.Properties["headers"])
+	assert.Equal(t, "regex", schema.Definitions["Rule"].Properties["hostRegexp"]["format"])
+	assert.Equal(t, []string{"key"}, schema.Definitions["Query"].Required)
+	assert.Equal(t, map[string]interface{}{}, schema.Definitions["PathMatcher"].Properties["args"])
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, path.Properties["priority"])
+	assert.Equal(t, []interface{}{"error", "last-wins", "priority"}, schema.Definitions["Config"].Properties["conflicts"]["enum"])
+}
+
+// TestJSONSchemaAgreesWithLoad checks that the schema and Load accept the same
+// configs, null included.
+func TestJSONSchemaAgreesWithLoad(t *testing.T) {
+	data, err := JSONSchema()
+	assert.Nil(t, err)
+	var schema map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &schema))
+
+	tests := []struct {
+		config string
+		valid  bool
+	}{
+		{config: `[{"host": "a.com", "paths": [{"path": "/a", "backend": "a", "methods": null, "headers": null, "queries": null, "vars": null}]}]`, valid: true},
+		{config: `{"include": null, "rules": [{"paths": null}]}`, valid: true},
+		{config: `[{"paths": [{"path": "/a", "backend": "a", "pathType": "Prefix", "methods": ["GET"], "priority": 1}]}]`, valid: true},
+		{config: `[{"paths": [{"path": "/a", "backend": null}]}]`},
+		{config: `[{"host": null, "paths": []}]`},
+		{config: `[{"paths": [{"path": "/a", "backend": "a", "methods": [null]}]}]`},
+		{config: `[{"paths": [{"path": "/a", "backend": "a", "pathType": null}]}]`},
+		{config: `[{"paths": [{"path": "/a", "backend": "a", "headers": [null]}]}]`},
+		{config: `[{"paths": [{"path": "a", "backend": "a"}]}]`},
+		{config: `[null]`},
+	}
+
+	for _, tt := range tests {
+		_, err := Load(strings.NewReader(tt.config), FormatJSON)
+		assert.Equal(t, tt.valid, err == nil, "Load %s: %v", tt.config, err)
+
+		var v interface{}
+		assert.Nil(t, json.Unmarshal([]byte(tt.config), &v))
+		errs := schemaErrors(schema, schema, v, "")
+		assert.Equal(t, tt.valid, len(errs) == 0, "schema %s: %v", tt.config, errs)
+	}
+}
+
+// schemaErrors validates a JSON value against the subset of JSON Schema that
+// JSONSchema generates.
+func schemaErrors(root, s map[string]interface{}, v interface{}, at string) []string {
+	if ref, ok := s["$ref"].(string); ok {
+		def := root["definitions"].(map[string]interface{})[strings.TrimPrefix(ref, "#/definitions/")]
+		return schemaErrors(root, def.(map[string]interface{}), v, at)
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		valid := 0
+		for _, sub := range oneOf {
+			if len(schemaErrors(root, sub.(map[string]interface{}), v, at)) == 0 {
+				valid++
+			}
+		}
+		if valid != 1 {
+			return []string{at + ": not exactly one of oneOf"}
+		}
+	}
+
+	typ := map[bool]string{true: "integer", false: "number"}
+	actual := ""
+	switch x := v.(type) {
+	case nil:
+		actual = "null"
+	case bool:
+		actual = "boolean"
+	case float64:
+		actual = typ[x == float64(int64(x))]
+	case string:
+		actual = "string"
+	case []interface{}:
+		actual = "array"
+	case map[string]interface{}:
+		actual = "object"
+	}
+	if t, ok := s["type"]; ok {
+		types := fmt.Sprint(t)
+		if !strings.Contains(types, actual) {
+			return []string{fmt.Sprintf("%s: %s is not %s", at, actual, types)}
+		}
+	}
+
+	errs := []string{}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v not in enum", at, v))
+		}
+	}
+	if pattern, ok := s["pattern"].(string); ok && actual == "string" && !regexp.MustCompile(pattern).MatchString(v.(string)) {
+		errs = append(errs, fmt.Sprintf("%s: %v does not match %s", at, v, pattern))
+	}
+
+	switch x := v.(type) {
+	case []interface{}:
+		items, _ := s["items"].(map[string]interface{})
+		for i, item := range x {
+			if items != nil {
+				errs = append(errs, schemaErrors(root, items, item, fmt.Sprintf("%s/%d", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := s["properties"].(map[string]interface{})
+		for key, value := range x {
+			p, ok := properties[key]
+			if !ok {
+				if s["additionalProperties"] == false {
+					errs = append(errs, fmt.Sprintf("%s: unknown property %s", at, key))
+				}
+				continue
+			}
+			errs = append(errs, schemaErrors(root, p.(map[string]interface{}), value, at+"/"+key)...)
+		}
+		required, _ := s["required"].([]interface{})
+		for _, key := range required {
+			if _, ok := x[key.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing %s", at, key))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,111 @@
+package router
+
+import "net/http"
+
+// searchAll collects the matches of SearchAll, a route is kept once with the
+// params of its first match.
+type searchAll struct {
+	matches []*Context
+	seen    map[*Route]bool
+}
+
+// SearchAll returns a context for every route matching the request, with the
+// params captured for it, in the order of their priority: the rules in order
+// and in a rule the routes of the path cache before the routes of the tree in
+// the order the search visits them. The routes of a node are in the order
+// Search would pick them, the first context has the route Search finds.
+func (ar *ArtRouter) SearchAll(req *http.Request) []*Context {
+	context := newContext(req)
+	all := &searchAll{matches: []*Context{}, seen: map[*Route]bool{}}
+	context.all = all
+
+	host := requestHost(req)
+	path := req.URL.Path
+
+	for _, rule := range ar.rules {
+		if !rule.match(host) {
+			continue
+		}
+
+		if !rule.disablePathCache {
+			if routes, ok := rule.pathCache[path]; ok {
+				for _, r := range routes.matchAll(context) {
+					var values []string
+					if len(r.paramKeys) > 0 {
+						// a static variant of a route with optional params
+						values = make([]string, len(r.paramKeys))
+					}
+					all.add(context, r, values)
+				}
+			}
+		}
+
+		rule.root.find(path, context)
+	}
+
+	return all.matches
+}
+
+// collect adds the matching routes of a leaf node with the params captured so far.
+func (a *searchAll) collect(n *node, context *Context) {
+	for _, r := range n.routes.matchAll(context) {
+		values, ok := n.alignedParams(context.routeParams.Values, r)
+		if !ok {
+			values = append([]string(nil), context.routeParams.Values...)
+		}
+		a.add(context, r, values)
+	}
+}
+
+func (a *searchAll) add(context *Context, r *Route, values []string) {
+	if a.seen[r] {
+		return
+	}
+	a.seen[r] = true
+
+	c := *context
+	c.all = nil
+	c.Route = r
+	c.routeParams = routeParams{Keys: append([]string(nil), r.paramKeys...), Values: values}
+	a.matches = append(a.matches, &c)
+}
+
+// matchAll returns the routes matching the context in the order match picks
+// them, as if the routes picked before were removed.
+func (rs Routes) matchAll(context *Context) []*Route {
+	matching := Routes{}
+	qualities := []float64{}
+	for _, r := range rs {
+		if q := r.matchQuality(context); q > 0 {
+			matching = append(matching, r)
+			qualities = append(qualities, q)
+		}
+	}
+
+	ordered := make([]*Route, 0, len(matching))
+	for len(matching) > 0 {
+		i := matching.pick(qualities)
+		ordered = append(ordered, matching[i])
+		matching = append(matching[:i:i], matching[i+1:]...)
+		qualities = append(qualities[:i:i], qualities[i+1:]...)
+	}
+	return ordered
+}
+
+// pick returns the index of the route match picks among matching routes with
+// their match qualities.
+func (rs Routes) pick(qualities []float64) int {
+	best, bestQ := -1, 0.0
+	for i, r := range rs {
+		if !r.negotiates() {
+			if best >= 0 {
+				return best
+			}
+			return i
+		}
+		if q := qualities[i]; q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchAll(t *testing.T) {
+	router := New([]*Rule{
+		{Host: "a.com", Paths: []*Path{
+			{Path: "/users/me", Backend: "me"},
+			{Path: "/users/{id:int}", Backend: "int"},
+			{Path: "/users/{name}", Methods: []string{"POST"}, Backend: "post"},
+			{Path: "/users/{name}", Backend: "name"},
+			{Path: "/users/*", Backend: "all"},
+			{Path: "/docs", Produces: []string{"text/html"}, Backend: "html"},
+			{Path: "/docs", Produces: []string{"application/json"}, Backend: "json"},
+			{Path: "/docs[/{page}]", Backend: "docs"},
+		}},
+		{Host: "b.com", Paths: []*Path{{Path: "/users/{id}", Backend: "b"}}},
+		{Paths: []*Path{{Path: "/users/{user}", Backend: "fallback"}}},
+	}, false)
+
+	type match struct {
+		backend string
+		params  map[string]string
+	}
+	tests := []struct {
+		url    string
+		accept string
+		want   []match
+	}{
+		{
+			url: "http://a.com/users/me",
+			want: []match{
+				{"me", map[string]string{}},
+				{"name", map[string]string{"name": "me"}},
+				{"all", map[string]string{"*": "me"}},
+				{"fallback", map[string]string{"user": "me"}},
+			},
+		},
+		{
+			url: "http://a.com/users/42",
+			want: []match{
+				{"int", map[string]string{"id": "42"}},
+				{"name", map[string]string{"name": "42"}},
+				{"all", map[string]string{"*": "42"}},
+				{"fallback", map[string]string{"user": "42"}},
+			},
+		},
+		{
+			url:  "http://b.com/users/1/2",
+			want: []match{},
+		},
+		{
+			url:    "http://a.com/docs",
+			accept: "application/json, text/html;q=0.5",
+			want: []match{
+				{"json", map[string]string{}},
+				{"html", map[string]string{}},
+				{"docs", map[string]string{"page": ""}},
+			},
+		},
+		{
+			url:  "http://a.com/docs/intro",
+			want: []match{{"docs", map[string]string{"page": "intro"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+
+		got := []match{}
+		for _, c := range router.SearchAll(req) {
+			params := map[string]string{}
+			for _, k := range c.Route.paramKeys {
+				params[k] = c.GetParam(k)
+			}
+			got = append(got, match{c.Route.backend, params})
+		}
+		assert.Equal(t, tt.want, got, tt.url)
+
+		// the first match is the one of Search
+		if first := router.Search(req).Route; first != nil {
+			assert.Equal(t, first.backend, got[0].backend, tt.url)
+		} else {
+			assert.Empty(t, got, tt.url)
+		}
+	}
+}
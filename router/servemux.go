@@ -0,0 +1,372 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PathTypeServeMux interprets Path as a net/http ServeMux path pattern, a
+// trailing slash matches the whole subtree, {name...} captures the rest of the
+// path and {$} anchors a trailing slash.
+const PathTypeServeMux = "ServeMux"
+
+type (
+	// ServeMux collects patterns in the syntax of the net/http ServeMux of
+	// Go 1.22, "[METHOD ][HOST]/[PATH]", and maps them onto rules. Like
+	// http.ServeMux it rejects conflicting patterns and the most specific
+	// pattern wins.
+	ServeMux struct {
+		patterns []*serveMuxPattern
+	}
+
+	serveMuxPattern struct {
+		str      string
+		method   string
+		host     string
+		rawPath  string
+		backend  string
+		segments []serveMuxSegment
+	}
+
+	serveMuxSegment struct {
+		s     string // literal or wildcard name
+		wild  bool
+		multi bool
+	}
+
+	relationship uint8
+)
+
+const (
+	equivalent relationship = iota
+	moreGeneral
+	moreSpecific
+	disjoint
+	overlaps
+)
+
+// Handle adds the pattern routing to backend, it returns an error if the
+// pattern is invalid or conflicts with a pattern added before.
+func (m *ServeMux) Handle(pattern, backend string) error {
+	p, err := parseServeMuxPattern(pattern)
+	if err != nil {
+		return err
+	}
+	p.backend = backend
+
+	for _, q := range m.patterns {
+		if p.conflictsWith(q) {
+			return fmt.Errorf("pattern %q conflicts with pattern %q", p.str, q.str)
+		}
+	}
+
+	m.patterns = append(m.patterns, p)
+	return nil
+}
+
+// Rules returns the rules of the patterns, the rules with a host come before
+// the one without, as host specific patterns take precedence.
+func (m *ServeMux) Rules() []*Rule {
+	rules := []*Rule{}
+	hosts := map[string]*Rule{}
+
+	for _, p := range m.patterns {
+		if _, ok := hosts[p.host]; !ok && p.host != "" {
+			hosts[p.host] = &Rule{Host: p.host}
+			rules = append(rules, hosts[p.host])
+		}
+	}
+
+	var anyHost *Rule
+	for _, p := range m.patterns {
+		rule := hosts[p.host]
+		if p.host == "" {
+			if anyHost == nil {
+				anyHost = &Rule{}
+			}
+			rule = anyHost
+		}
+		rule.Paths = append(rule.Paths, p.path())
+	}
+	if anyHost != nil {
+		rules = append(rules, anyHost)
+	}
+
+	for _, rule := range rules {
+		// the tree orders static before params before wildcards, which leaves
+		// the method specific patterns to be ordered before the generic ones
+		sort.SliceStable(rule.Paths, func(i, j int) bool {
+			return methodRank(rule.Paths[i].Methods) < methodRank(rule.Paths[j].Methods)
+		})
+	}
+
+	return rules
+}
+
+func methodRank(methods []string) int {
+	switch len(methods) {
+	case 0:
+		return 2
+	case 1:
+		return 0
+	}
+	return 1
+}
+
+func (p *serveMuxPattern) path() *Path {
+	path := &Path{
+		Path:     p.rawPath,
+		PathType: PathTypeServeMux,
+		Backend:  p.backend,
+	}
+	switch p.method {
+	case "":
+	case http.MethodGet:
+		path.Methods = []string{http.MethodGet, http.MethodHead}
+	default:
+		path.Methods = []string{p.method}
+	}
+	return path
+}
+
+// routerPattern converts the path of the pattern into the router syntax.
+func (p *serveMuxPattern) routerPattern() string {
+	var sb strings.Builder
+	for _, seg := range p.segments {
+		sb.WriteByte('/')
+		switch {
+		case seg.multi && seg.s == "":
+			sb.WriteByte('*')
+		case seg.multi:
+			sb.WriteString("{" + seg.s + "*}")
+		case seg.wild:
+			sb.WriteString("{" + seg.s + "}")
+		default:
+			sb.WriteString(seg.s)
+		}
+	}
+	return sb.String()
+}
+
+func parseServeMuxPattern(s string) (*serveMuxPattern, error) {
+	p := &serveMuxPattern{str: s}
+
+	rest := s
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		p.method = s[:i]
+		rest = strings.TrimLeft(s[i:], " \t")
+		if _, ok := methodMap[p.method]; !ok {
+			return nil, fmt.Errorf("pattern %q: unsupported method %q", s, p.method)
+		}
+	}
+
+	i := strings.IndexByte(rest, '/')
+	if i < 0 {
+		return nil, fmt.Errorf("pattern %q: host/path missing /", s)
+	}
+	p.host = rest[:i]
+	p.rawPath = rest[i:]
+
+	segments, err := parseServeMuxPath(rest[i:])
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %v", s, err)
+	}
+	p.segments = segments
+
+	return p, nil
+}
+
+// parseServeMuxPath splits the path into segments, a trailing slash becomes an
+// anonymous multi segment and {$} an empty literal segment.
+func parseServeMuxPath(path string) ([]serveMuxSegment, error) {
+	segments := []serveMuxSegment{}
+	names := []string{}
+
+	rest := path[1:]
+	for {
+		if rest == "" {
+			segments = append(segments, serveMuxSegment{multi: true})
+			return segments, nil
+		}
+
+		seg := rest
+		last := true
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			seg, rest, last = rest[:i], rest[i+1:], false
+		}
+
+		if !strings.ContainsAny(seg, "{}") {
+			if strings.ContainsAny(seg, "*[]") {
+				return nil, fmt.Errorf("unsupported character in segment %q", seg)
+			}
+			segments = append(segments, serveMuxSegment{s: seg})
+			if last {
+				return segments, nil
+			}
+			continue
+		}
+
+		if seg[0] != '{' || seg[len(seg)-1] != '}' || strings.Count(seg, "{") != 1 {
+			return nil, fmt.Errorf("bad wildcard segment %q, it must fill the entire segment", seg)
+		}
+
+		name := seg[1 : len(seg)-1]
+		if name == "$" {
+			if !last {
+				return nil, fmt.Errorf("{$} not at end")
+			}
+			segments = append(segments, serveMuxSegment{})
+			return segments, nil
+		}
+
+		multi := strings.HasSuffix(name, "...")
+		if multi {
+			name = name[:len(name)-3]
+			if !last {
+				return nil, fmt.Errorf("{%s...} wildcard not at end", name)
+			}
+		}
+
+		if !isGoIdent(name) {
+			return nil, fmt.Errorf("bad wildcard name %q", name)
+		}
+		if StrInSlice(name, names) {
+			return nil, fmt.Errorf("duplicate wildcard name %q", name)
+		}
+		names = append(names, name)
+
+		segments = append(segments, serveMuxSegment{s: name, wild: !multi, multi: multi})
+		if last {
+			return segments, nil
+		}
+	}
+}
+
+func isGoIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || isLetter(c) || (i > 0 && isDigit(c)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// conflictsWith reports whether both patterns match some request and neither
+// is more specific than the other, host specific patterns never conflict with
+// host-less ones.
+func (p *serveMuxPattern) conflictsWith(q *serveMuxPattern) bool {
+	if p.host != q.host {
+		return false
+	}
+	rel := p.compareMethods(q)
+	if rel == disjoint {
+		return false
+	}
+	rel = combineRelationships(rel, p.comparePaths(q))
+	return rel == equivalent || rel == overlaps
+}
+
+func (p *serveMuxPattern) compareMethods(q *serveMuxPattern) relationship {
+	switch {
+	case p.method == q.method:
+		return equivalent
+	case p.method == "":
+		return moreGeneral
+	case q.method == "":
+		return moreSpecific
+	case p.method == http.MethodGet && q.method == http.MethodHead:
+		return moreGeneral
+	case q.method == http.MethodGet && p.method == http.MethodHead:
+		return moreSpecific
+	}
+	return disjoint
+}
+
+func (p *serveMuxPattern) comparePaths(q *serveMuxPattern) relationship {
+	rel := equivalent
+	segs1, segs2 := p.segments, q.segments
+
+	for ; len(segs1) > 0 && len(segs2) > 0; segs1, segs2 = segs1[1:], segs2[1:] {
+		s1, s2 := segs1[0], segs2[0]
+		switch {
+		case s1.multi && s2.multi:
+			return combineRelationships(rel, equivalent)
+		case s1.multi:
+			return combineRelationships(rel, moreGeneral)
+		case s2.multi:
+			return combineRelationships(rel, moreSpecific)
+		}
+		rel = combineRelationships(rel, compareSegments(s1, s2))
+		if rel == disjoint {
+			return rel
+		}
+	}
+
+	if len(segs1) != len(segs2) {
+		// a multi segment matches at least one segment
+		return disjoint
+	}
+	return rel
+}
+
+// compareSegments compares two single segments, a wildcard never matches an
+// empty segment.
+func compareSegments(s1, s2 serveMuxSegment) relationship {
+	switch {
+	case s1.wild && s2.wild:
+		return equivalent
+	case s1.wild:
+		if s2.s == "" {
+			return disjoint
+		}
+		return moreGeneral
+	case s2.wild:
+		if s1.s == "" {
+			return disjoint
+		}
+		return moreSpecific
+	case s1.s == s2.s:
+		return equivalent
+	}
+	return disjoint
+}
+
+func combineRelationships(r1, r2 relationship) relationship {
+	switch r1 {
+	case equivalent:
+		return r2
+	case disjoint:
+		return disjoint
+	case overlaps:
+		if r2 == disjoint {
+			return disjoint
+		}
+		return overlaps
+	}
+
+	// moreGeneral or moreSpecific
+	switch r2 {
+	case equivalent:
+		return r1
+	case inverseRelationship(r1):
+		return overlaps
+	}
+	return r2
+}
+
+func inverseRelationship(r relationship) relationship {
+	switch r {
+	case moreGeneral:
+		return moreSpecific
+	case moreSpecific:
+		return moreGeneral
+	}
+	return r
+}
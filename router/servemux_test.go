@@ -0,0 +1,120 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeMux(t *testing.T) {
+	mux := &ServeMux{}
+
+	for _, p := range [][2]string{
+		{"/", "root"},
+		{"/{$}", "index"},
+		{"/items/{id}", "item-any"},
+		{"GET /items/{id}", "item"},
+		{"POST /items/{id}", "item-update"},
+		{"GET /items/new", "item-new"},
+		{"/files/{path...}", "files"},
+		{"GET example.com/items/{id}", "example-item"},
+		{"HEAD /items/{id}/", "item-head"},
+		{"/static/", "static"},
+		{"DELETE /static/css/{$}", "static-css"},
+		{"GET /repos/{owner}/{repo}/tree", "tree"},
+	} {
+		assert.Nil(t, mux.Handle(p[0], p[1]), p[0])
+	}
+
+	rules := mux.Rules()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "example.com", rules[0].Host)
+	assert.Equal(t, "", rules[1].Host)
+
+	tests := []struct {
+		m    string
+		host string
+		r    string
+		h    string
+		k    []string
+		v    []string
+	}{
+		{m: "GET", r: "/", h: "index"},
+		{m: "GET", r: "/x", h: "root", k: []string{"*"}, v: []string{"x"}},
+		{m: "GET", r: "/items/1", h: "item", k: []string{"id"}, v: []string{"1"}},
+		{m: "HEAD", r: "/items/1", h: "item", k: []string{"id"}, v: []string{"1"}},
+		{m: "HEAD", r: "/items/1/x", h: "item-head", k: []string{"id", "*"}, v: []string{"1", "x"}},
+		{m: "POST", r: "/items/1", h: "item-update", k: []string{"id"}, v: []string{"1"}},
+		{m: "PUT", r: "/items/1", h: "item-any", k: []string{"id"}, v: []string{"1"}},
+		{m: "GET", r: "/items/new", h: "item-new"},
+		{m: "HEAD", r: "/items/new", h: "item-new"},
+		{m: "POST", r: "/items/new", h: "item-update", k: []string{"id"}, v: []string{"new"}},
+		{m: "GET", host: "example.com", r: "/items/1", h: "example-item", k: []string{"id"}, v: []string{"1"}},
+		{m: "POST", host: "example.com", r: "/items/1", h: "item-update", k: []string{"id"}, v: []string{"1"}},
+		{m: "GET", r: "/files/", h: "files", k: []string{"path"}, v: []string{""}},
+		{m: "GET", r: "/files/a/b", h: "files", k: []string{"path"}, v: []string{"a/b"}},
+		{m: "GET", r: "/static", h: "root", k: []string{"*"}, v: []string{"static"}},
+		{m: "GET", r: "/static/css/", h: "static", k: []string{"*"}, v: []string{"css/"}},
+		{m: "DELETE", r: "/static/css/", h: "static-css"},
+		{m: "GET", r: "/repos/go/go/tree", h: "tree", k: []string{"owner", "repo"}, v: []string{"go", "go"}},
+	}
+
+	router := New(rules, false)
+
+	for _, tt := range tests {
+		host := tt.host
+		if host == "" {
+			host = "other.com"
+		}
+		req, _ := http.NewRequest(tt.m, "http://"+host+tt.r, nil)
+		context := router.Search(req)
+
+		var backend string
+
+		if context.Route != nil {
+			backend = context.Route.backend
+		}
+
+		assert.Equal(t, tt.h, backend, "%s %s%s", tt.m, host, tt.r)
+		assert.Equal(t, tt.k, context.routeParams.Keys, "%s %s%s", tt.m, host, tt.r)
+		if tt.v != nil {
+			assert.Equal(t, tt.v, context.routeParams.Values, "%s %s%s", tt.m, host, tt.r)
+		}
+	}
+}
+
+func TestServeMuxConflicts(t *testing.T) {
+	tests := []struct {
+		p1, p2   string
+		conflict bool
+	}{
+		{p1: "/a", p2: "/a", conflict: true},
+		{p1: "/a/{x}", p2: "/a/{y}", conflict: true},
+		{p1: "/a/{x}", p2: "/{y}/b", conflict: true},
+		{p1: "/a/{x...}", p2: "/{y}/b", conflict: true},
+		{p1: "GET /a", p2: "/{x}", conflict: false},
+		{p1: "GET /{x}", p2: "/a", conflict: true},
+		{p1: "GET /a", p2: "POST /a", conflict: false},
+		{p1: "GET /a", p2: "HEAD /a", conflict: false},
+		{p1: "GET /items/new", p2: "HEAD /items/{id}", conflict: true},
+		{p1: "a.com/a", p2: "/a", conflict: false},
+		{p1: "/a/", p2: "/a/{x}", conflict: false},
+		{p1: "/a/{$}", p2: "/a/{x}", conflict: false},
+		{p1: "/a/{$}", p2: "/a/", conflict: false},
+		{p1: "/a", p2: "/a/", conflict: false},
+		{p1: "/a/b", p2: "/a/b/c", conflict: false},
+	}
+
+	for _, tt := range tests {
+		mux := &ServeMux{}
+		assert.Nil(t, mux.Handle(tt.p1, "b1"))
+		err := mux.Handle(tt.p2, "b2")
+		assert.Equal(t, tt.conflict, err != nil, "%s %s", tt.p1, tt.p2)
+	}
+
+	mux := &ServeMux{}
+	for _, p := range []string{"/a{x}", "/{x}/{x}", "/{x...}/a", "/{$}/a", "FETCH /a", "a.com", "/{1x}", "/a*"} {
+		assert.NotNil(t, mux.Handle(p, "b"), p)
+	}
+}
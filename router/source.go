@@ -0,0 +1,356 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// ConfigSnapshot is a version of the rules of a source, Err is set if the
+	// source failed to load them.
+	ConfigSnapshot struct {
+		Rules   []*Rule
+		Version string
+		Err     error
+	}
+
+	// ConfigSource streams snapshots of rules.
+	ConfigSource interface {
+		// Watch sends the current snapshot and then one for every change,
+		// until the context is done.
+		Watch(ctx context.Context, snapshots chan<- *ConfigSnapshot)
+	}
+
+	// FileSource polls a config file, see LoadFile, the version is the hash of
+	// its content and the content of the files it includes.
+	FileSource struct {
+		Filename string
+		// Interval is the polling interval, 1s by default
+		Interval time.Duration
+	}
+
+	// DirSource polls the config files of a directory, the fragments are read
+	// in the order of their names and composed like the includes of a Config.
+	// The version is the hash of the names and contents.
+	DirSource struct {
+		Dir string
+		// Interval is the polling interval, 1s by default
+		Interval time.Duration
+		// Conflicts is the conflict mode of the fragments, ConflictError by default
+		Conflicts string
+	}
+
+	// HTTPSource polls a config from a URL, the ETag of the response is the
+	// version and is sent back in If-None-Match, the hash of the body is used
+	// without ETag.
+	HTTPSource struct {
+		URL    string
+		Client *http.Client
+		// Format is FormatJSON or FormatYAML, by default it's told by the
+		// Content-Type of the response, JSON unless it mentions yaml
+		Format string
+		// Interval is the polling interval, 1s by default
+		Interval time.Duration
+	}
+
+	// MemorySource is a source set by the program, e.g. in tests.
+	MemorySource struct {
+		snapshot *ConfigSnapshot
+		notify   chan struct{} // closed on Set
+		mu       sync.Mutex
+	}
+
+	// SourceOptions configures a SourceRouter.
+	SourceOptions struct {
+		// DisablePathCache is passed to New
+		DisablePathCache bool
+		// OnUpdate is called after each snapshot with the error of a failed one
+		OnUpdate func(version string, err error)
+	}
+
+	// SourceRouter keeps a router in sync with a config source, a failed
+	// snapshot keeps the current router. Until the first snapshot the router
+	// has no rules.
+	SourceRouter struct {
+		router  atomic.Value // *ArtRouter
+		version atomic.Value // string
+		cancel  context.CancelFunc
+		done    chan struct{}
+	}
+)
+
+// NewSourceRouter starts watching the source.
+func NewSourceRouter(src ConfigSource, opts SourceOptions) *SourceRouter {
+	ctx, cancel := context.WithCancel(context.Background())
+	sr := &SourceRouter{cancel: cancel, done: make(chan struct{})}
+
+	empty := New(nil, opts.DisablePathCache)
+	sr.router.Store(&empty)
+	sr.version.Store("")
+
+	snapshots := make(chan *ConfigSnapshot)
+	go func() {
+		src.Watch(ctx, snapshots)
+		close(snapshots)
+	}()
+
+	go func() {
+		defer close(sr.done)
+		for s := range snapshots {
+			err := s.Err
+			if err == nil {
+				var ar *ArtRouter
+				if ar, err = buildRouter(s.Rules, opts.DisablePathCache); err == nil {
+					sr.router.Store(ar)
+					sr.version.Store(s.Version)
+				}
+			}
+			if opts.OnUpdate != nil {
+				opts.OnUpdate(s.Version, err)
+			}
+		}
+	}()
+
+	return sr
+}
+
+// Router returns the current router, it's replaced as a whole on update.
+func (sr *SourceRouter) Router() *ArtRouter {
+	return sr.router.Load().(*ArtRouter)
+}
+
+// Search searches the current router.
+func (sr *SourceRouter) Search(req *http.Request) *Context {
+	return sr.Router().Search(req)
+}
+
+// Version returns the version of the current router, empty before the first
+// snapshot.
+func (sr *SourceRouter) Version() string {
+	return sr.version.Load().(string)
+}
+
+// Close stops watching the source.
+func (sr *SourceRouter) Close() {
+	sr.cancel()
+	<-sr.done
+}
+
+// Watch implements ConfigSource.
+func (s *FileSource) Watch(ctx context.Context, snapshots chan<- *ConfigSnapshot) {
+	pollSource(ctx, s.Interval, snapshots, func(last string) *ConfigSnapshot {
+		c := newConfigComposer()
+		if err := c.addFile(s.Filename, true); err != nil {
+			return &ConfigSnapshot{Version: c.version(), Err: err}
+		}
+		version := c.version()
+		if version == last {
+			return nil
+		}
+		rules, err := c.compose("")
+		return &ConfigSnapshot{Rules: rules, Version: version, Err: err}
+	})
+}
+
+// Watch implements ConfigSource.
+func (s *DirSource) Watch(ctx context.Context, snapshots chan<- *ConfigSnapshot) {
+	pollSource(ctx, s.Interval, snapshots, func(last string) *ConfigSnapshot {
+		files, err := configFiles(s.Dir)
+		if err != nil {
+			return &ConfigSnapshot{Err: err}
+		}
+
+		c := newConfigComposer()
+		for _, f := range files {
+			if err := c.addFile(f, false); err != nil {
+				return &ConfigSnapshot{Version: c.version(), Err: err}
+			}
+		}
+		version := c.version()
+		if version == last {
+			return nil
+		}
+		rules, err := c.compose(s.Conflicts)
+		return &ConfigSnapshot{Rules: rules, Version: version, Err: err}
+	})
+}
+
+// configFiles returns the JSON and YAML files of the directory sorted by name.
+func configFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, err := formatOf(e.Name()); err == nil {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Watch implements ConfigSource.
+func (s *HTTPSource) Watch(ctx context.Context, snapshots chan<- *ConfigSnapshot) {
+	etag := ""
+	pollSource(ctx, s.Interval, snapshots, func(last string) *ConfigSnapshot {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+		if err != nil {
+			return &ConfigSnapshot{Err: err}
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		client := s.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return &ConfigSnapshot{Err: err}
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotModified {
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return &ConfigSnapshot{Err: fmt.Errorf("%s: unexpected status %s", s.URL, resp.Status)}
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return &ConfigSnapshot{Err: err}
+		}
+
+		version := resp.Header.Get("ETag")
+		if version == "" {
+			version = hashVersion(data)
+		}
+		if version == last {
+			return nil
+		}
+
+		format := s.Format
+		if format == "" {
+			format = FormatJSON
+			if strings.Contains(resp.Header.Get("Content-Type"), "yaml") {
+				format = FormatYAML
+			}
+		}
+		rules, err := load(bytes.NewReader(data), format, s.URL)
+		if err == nil {
+			// a version failing to load is fetched again at the next poll
+			etag = resp.Header.Get("ETag")
+		}
+		return &ConfigSnapshot{Rules: rules, Version: version, Err: err}
+	})
+}
+
+// pollSource calls poll at once and then at every interval, poll returns nil
+// if the version didn't change since last. A failed snapshot is sent once per
+// error message, not at every poll.
+func pollSource(ctx context.Context, interval time.Duration, snapshots chan<- *ConfigSnapshot, poll func(last string) *ConfigSnapshot) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, lastErr := "", ""
+	for {
+		s := poll(last)
+		if s == nil {
+			// back to the last version
+			lastErr = ""
+		} else {
+			send := true
+			if s.Err != nil {
+				send = s.Err.Error() != lastErr
+				lastErr = s.Err.Error()
+			} else {
+				lastErr = ""
+			}
+			// a failed version is loaded again once it changes
+			if s.Err == nil {
+				last = s.Version
+			}
+			if send {
+				select {
+				case snapshots <- s:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func hashVersion(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// NewMemorySource returns a source without rules until Set is called.
+func NewMemorySource() *MemorySource {
+	return &MemorySource{notify: make(chan struct{})}
+}
+
+// Set replaces the rules of the source.
+func (s *MemorySource) Set(rules []*Rule, version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshot = &ConfigSnapshot{Rules: rules, Version: version}
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// Watch implements ConfigSource.
+func (s *MemorySource) Watch(ctx context.Context, snapshots chan<- *ConfigSnapshot) {
+	var last *ConfigSnapshot
+	for {
+		s.mu.Lock()
+		snapshot, notify := s.snapshot, s.notify
+		s.mu.Unlock()
+
+		if snapshot != nil && snapshot != last {
+			last = snapshot
+			select {
+			case snapshots <- snapshot:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sourceUpdate struct {
+	version string
+	err     error
+}
+
+func newTestSourceRouter(t *testing.T, src ConfigSource) (*SourceRouter, func() sourceUpdate) {
+	updates := make(chan sourceUpdate, 10)
+	sr := NewSourceRouter(src, SourceOptions{
+		OnUpdate: func(version string, err error) { updates <- sourceUpdate{version, err} },
+	})
+	t.Cleanup(sr.Close)
+
+	return sr, func() sourceUpdate {
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(2 * time.Second):
+			t.Fatal("no update")
+		}
+		return sourceUpdate{}
+	}
+}
+
+func sourceBackend(sr *SourceRouter, url string) string {
+	req, _ := http.NewRequest("GET", url, nil)
+	if context := sr.Search(req); context.Route != nil {
+		return context.Route.backend
+	}
+	return ""
+}
+
+func TestMemorySource(t *testing.T) {
+	src := NewMemorySource()
+	sr, next := newTestSourceRouter(t, src)
+	assert.Equal(t, "", sr.Version())
+	assert.Equal(t, "", sourceBackend(sr, "http://a.com/a"))
+
+	src.Set([]*Rule{{Paths: []*Path{{Path: "/a", Backend: "a1"}}}}, "v1")
+	assert.Equal(t, sourceUpdate{version: "v1"}, next())
+	assert.Equal(t, "v1", sr.Version())
+	assert.Equal(t, "a1", sourceBackend(sr, "http://a.com/a"))
+
+	// rules New rejects keep the current router
+	src.Set([]*Rule{{Paths: []*Path{{Path: "/a/{id", Backend: "a2"}}}}, "v2")
+	u := next()
+	assert.Equal(t, "v2", u.version)
+	assert.NotNil(t, u.err)
+	assert.Equal(t, "v1", sr.Version())
+	assert.Equal(t, "a1", sourceBackend(sr, "http://a.com/a"))
+}
+
+func TestFileSource(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.yaml")
+	assert.Nil(t, os.WriteFile(filename, []byte("- paths: [{path: /a, backend: a1}]\n"), 0o644))
+
+	sr, next := newTestSourceRouter(t, &FileSource{Filename: filename, Interval: 5 * time.Millisecond})
+	assert.Nil(t, next().err)
+	assert.Equal(t, "a1", sourceBackend(sr, "http://a.com/a"))
+	v1 := sr.Version()
+	assert.Len(t, v1, 64)
+
+	assert.Nil(t, os.WriteFile(filename, []byte("- paths: [{path: /a}]\n"), 0o644))
+	assert.NotNil(t, next().err)
+	assert.Equal(t, v1, sr.Version())
+
+	assert.Nil(t, os.WriteFile(filename, []byte("- paths: [{path: /a, backend: a2}]\n"), 0o644))
+	assert.Nil(t, next().err)
+	assert.Equal(t, "a2", sourceBackend(sr, "http://a.com/a"))
+	assert.NotEqual(t, v1, sr.Version())
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "10-a.yaml"), []byte("- host: a.com\n  paths: [{path: /a, backend: a}]\n"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "20-b.json"), []byte(`[{"paths": [{"path": "/b", "backend": "b"}]}]`), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a config"), 0o644))
+
+	src := &DirSource{Dir: dir, Interval: 5 * time.Millisecond}
+	sr, next := newTestSourceRouter(t, src)
+	assert.Nil(t, next().err)
+	assert.Equal(t, "a", sourceBackend(sr, "http://a.com/a"))
+	assert.Equal(t, "b", sourceBackend(sr, "http://b.com/b"))
+
+	// the fragments of a.com are merged into one rule
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "30-a.yaml"), []byte("- host: a.com\n  paths: [{path: /c, backend: c}]\n"), 0o644))
+	assert.Nil(t, next().err)
+	assert.Equal(t, []RouteInfo{
+		{Host: "a.com", Pattern: "/a", Backend: "a", Predicates: []string{}},
+		{Host: "a.com", Pattern: "/c", Backend: "c", Predicates: []string{}},
+		{Pattern: "/b", Backend: "b", Predicates: []string{}},
+	}, sr.Router().RouteTable())
+
+	// a conflict between the fragments fails the snapshot
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "40-a.yaml"), []byte("- host: a.com\n  paths: [{path: /c, backend: c2}]\n"), 0o644))
+	assert.Contains(t, next().err.Error(), "40-a.yaml:2:11: path /c conflicts on host a.com for any method with the path at "+filepath.Join(dir, "30-a.yaml:2:11"))
+	assert.Equal(t, "c", sourceBackend(sr, "http://a.com/c"))
+}
+
+func TestHTTPSource(t *testing.T) {
+	var mu sync.Mutex
+	body, etag := `[{"paths": [{"path": "/a", "backend": "a1"}]}]`, `"v1"`
+	requests, notModified := 0, 0
+	ifNoneMatch := []string{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	sr, next := newTestSourceRouter(t, &HTTPSource{URL: ts.URL, Interval: 5 * time.Millisecond})
+	assert.Equal(t, sourceUpdate{version: `"v1"`}, next())
+	assert.Equal(t, "a1", sourceBackend(sr, "http://a.com/a"))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return notModified >= 2
+	}, 2*time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	body, etag = "- paths: [{path: /a, backend: a2}]\n", `"v2"`
+	mu.Unlock()
+
+	// the content type tells JSON, the YAML body fails to parse
+	assert.NotNil(t, next().err)
+
+	// the failed version is fetched again, not validated by its ETag
+	mu.Lock()
+	failedAt := requests
+	mu.Unlock()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests >= failedAt+2
+	}, 2*time.Second, 5*time.Millisecond)
+	mu.Lock()
+	assert.NotContains(t, ifNoneMatch, `"v2"`)
+	mu.Unlock()
+
+	src := &HTTPSource{URL: ts.URL, Format: FormatYAML, Interval: 5 * time.Millisecond}
+	sr2, next2 := newTestSourceRouter(t, src)
+	assert.Equal(t, sourceUpdate{version: `"v2"`}, next2())
+	assert.Equal(t, "a2", sourceBackend(sr2, "http://a.com/a"))
+	assert.Equal(t, "a1", sourceBackend(sr, "http://a.com/a"))
+}
+
+func TestPollSourceErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshots := make(chan *ConfigSnapshot, 10)
+	go (&FileSource{Filename: filepath.Join(t.TempDir(), "missing.yaml"), Interval: time.Millisecond}).Watch(ctx, snapshots)
+
+	s := <-snapshots
+	assert.NotNil(t, s.Err)
+
+	// the same error isn't sent again
+	select {
+	case s := <-snapshots:
+		t.Fatalf("unexpected snapshot: %v", s.Err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
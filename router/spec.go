@@ -1,6 +1,9 @@
 package router
 
-import "regexp"
+import (
+	"encoding/json"
+	"regexp"
+)
 
 type (
 	Rule struct {
@@ -9,6 +12,19 @@ type (
 		Paths      []*Path `json:"paths" jsonschema:"omitempty"`
 	}
 
+	// Config is the object form of a config file, a config that is a list of
+	// rules is the same as a Config with only Rules.
+	Config struct {
+		// Include lists the files or glob patterns of the configs to include,
+		// relative to the including file, their rules come before its own rules.
+		Include []string `json:"include,omitempty" jsonschema:"omitempty,uniqueItems=true"`
+		// Conflicts tells how the paths of a host from different files declaring
+		// the same method, pattern and predicates are handled: error (the
+		// default), last-wins or priority. It can only be set in the root config.
+		Conflicts string `json:"conflicts,omitempty" jsonschema:"omitempty,enum=error,enum=last-wins,enum=priority"`
+		Rules     []*Rule `json:"rules,omitempty" jsonschema:"omitempty"`
+	}
+
 	// Path is second level entry of router.
 	Path struct {
 		Path           string    `json:"path,omitempty" jsonschema:"omitempty,pattern=^/"`
@@ -17,6 +33,29 @@ type (
 		Headers        []*Header `json:"headers" jsonschema:"omitempty"`
 		Queries        []*Query  `json:"queries,omitempty" jsonschema:"omitempty"`
 		MatchAllHeader bool      `json:"matchAllHeader" jsonschema:"omitempty"`
+
+		// PathType determines how Path is interpreted, one of Exact, Prefix,
+		// RegularExpression, ServeMux and ImplementationSpecific, the default
+		// pattern syntax.
+		PathType string `json:"pathType,omitempty" jsonschema:"omitempty,enum=Exact,enum=Prefix,enum=RegularExpression,enum=ServeMux,enum=ImplementationSpecific"`
+
+		// Produces lists the media types served by the path, they are negotiated
+		// against the Accept header of the request, e.g. application/vnd.acme.v2+json.
+		Produces []string `json:"produces,omitempty" jsonschema:"omitempty,uniqueItems=true"`
+		// Consumes lists the media ranges accepted in the Content-Type header of
+		// the request, e.g. application/json or application/*.
+		Consumes []string `json:"consumes,omitempty" jsonschema:"omitempty,uniqueItems=true"`
+		// Languages lists the language tags served by the path, they are negotiated
+		// against the Accept-Language header of the request, e.g. en-US.
+		Languages []string `json:"languages,omitempty" jsonschema:"omitempty,uniqueItems=true"`
+		// Vars is an expression on the request variables in the style of the vars of
+		// lua-resty-radixtree, e.g. [["arg_name","==","json"],["http_x","~~","^v2"]].
+		Vars []interface{} `json:"vars,omitempty" jsonschema:"omitempty"`
+		// Matchers are custom predicates registered by RegisterMatcher, all of them must match.
+		Matchers []*PathMatcher `json:"matchers,omitempty" jsonschema:"omitempty"`
+		// Priority settles the conflicts of a composed config in the priority
+		// mode, the path with the highest priority wins, see Config.
+		Priority int `json:"priority,omitempty" jsonschema:"omitempty"`
 	}
 
 	// Header is the third level entry of router. A header entry is always under a specific path entry, that is to mean
@@ -36,6 +75,12 @@ type (
 		Regexp string   `json:"regexp,omitempty" jsonschema:"omitempty,format=regexp"`
 		Values []string `json:"values,omitempty" jsonschema:"omitempty,uniqueItems=true"`
 	}
+
+	// PathMatcher references a custom matcher by name, Args is passed to its factory.
+	PathMatcher struct {
+		Matcher string          `json:"matcher" jsonschema:"required"`
+		Args    json.RawMessage `json:"args,omitempty" jsonschema:"omitempty"`
+	}
 )
 
 
@@ -0,0 +1,183 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type templateHelper struct {
+	args int
+	fn   func(s string, args []string) string
+}
+
+var (
+	varNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// templateUnescaper unescapes the braces and pipes of defaults and arguments
+	templateUnescaper = strings.NewReplacer(`\{`, "{", `\}`, "}", `\|`, "|")
+
+	// templateHelpers are the helpers applied with a pipe, e.g. ${ENV | upper}
+	templateHelpers = map[string]templateHelper{
+		"lower":      {0, func(s string, _ []string) string { return strings.ToLower(s) }},
+		"upper":      {0, func(s string, _ []string) string { return strings.ToUpper(s) }},
+		"trim":       {0, func(s string, _ []string) string { return strings.TrimSpace(s) }},
+		"quoteMeta":  {0, func(s string, _ []string) string { return regexp.QuoteMeta(s) }},
+		"trimPrefix": {1, func(s string, args []string) string { return strings.TrimPrefix(s, args[0]) }},
+		"trimSuffix": {1, func(s string, args []string) string { return strings.TrimSuffix(s, args[0]) }},
+		"replace":    {2, func(s string, args []string) string { return strings.ReplaceAll(s, args[0], args[1]) }},
+	}
+)
+
+// expandTemplate substitutes the variables of s, ${VAR} or ${VAR:-default}
+// where the default is used if VAR is unset or empty, followed by helpers, e.g.
+// ${HOST:-example.com | quoteMeta}. The arguments of the helpers are split on
+// spaces. The braces of the default and the arguments must be balanced, like in
+// ${RE:-[0-9]{4}}, or escaped as \{ and \}, a pipe is escaped as \|. $${ is a
+// literal ${. It returns an error for every unresolved variable, invalid
+// helper or unterminated ${.
+func expandTemplate(s string, lookup func(string) (string, bool)) (string, []error) {
+	var sb strings.Builder
+	errs := []error{}
+
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		parts, n := splitTemplate(s[i+2:])
+		if parts == nil {
+			errs = append(errs, fmt.Errorf("unterminated '%s'", s[i:]))
+			sb.WriteString(s)
+			break
+		}
+		m := s[i : i+2+n]
+		value, err := expandVariable(m, parts, lookup)
+		if err != nil {
+			errs = append(errs, err)
+			value = m
+		}
+		sb.WriteString(s[:i] + value)
+		s = s[i+2+n:]
+	}
+
+	return sb.String(), errs
+}
+
+// splitTemplate splits the expression after ${ on the pipes, it returns the
+// parts, escapes included, and the length of the expression with its closing
+// brace, or nil if the expression is unterminated.
+func splitTemplate(s string) ([]string, int) {
+	parts := []string{}
+	depth, start := 1, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("{}|", s[i+1]) >= 0 {
+				i++
+			}
+		case '{':
+			depth++
+		case '|':
+			if depth == 1 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				return append(parts, s[start:i]), i + 1
+			}
+		}
+	}
+	return nil, 0
+}
+
+// expandVariable expands the expression m split into its parts.
+func expandVariable(m string, parts []string, lookup func(string) (string, bool)) (string, error) {
+	name, def, hasDefault := strings.TrimSpace(parts[0]), "", false
+	if i := strings.Index(name, ":-"); i >= 0 {
+		name, def, hasDefault = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+2:]), true
+	}
+	if !varNameRE.MatchString(name) {
+		return "", fmt.Errorf("invalid variable name '%s'", name)
+	}
+
+	value, ok := lookup(name)
+	if value == "" && hasDefault {
+		value, ok = templateUnescaper.Replace(def), true
+	}
+	if !ok {
+		return "", fmt.Errorf("unresolved variable '%s'", name)
+	}
+
+	for _, p := range parts[1:] {
+		fields := strings.Fields(p)
+		if len(fields) == 0 {
+			return "", fmt.Errorf("empty helper in '%s'", m)
+		}
+		h, ok := templateHelpers[fields[0]]
+		if !ok {
+			return "", fmt.Errorf("unknown helper '%s'", fields[0])
+		}
+		if len(fields)-1 != h.args {
+			return "", fmt.Errorf("helper '%s' expects %d arguments", fields[0], h.args)
+		}
+		args := fields[1:]
+		for i := range args {
+			args[i] = templateUnescaper.Replace(args[i])
+		}
+		value = h.fn(value, args)
+	}
+	return value, nil
+}
+
+// expandConfig substitutes the variables of the environment in the values of
+// the config, the keys are left as is. A plain YAML scalar gets the type of its
+// new value, e.g. priority: ${PRIORITY} is an integer.
+func expandConfig(node *yaml.Node, file string) error {
+	v := &configValidator{file: file}
+	v.expand(node, os.LookupEnv)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+func (v *configValidator) expand(node *yaml.Node, lookup func(string) (string, bool)) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			v.expand(n, lookup)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			v.expand(node.Content[i], lookup)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return
+		}
+		value, errs := expandTemplate(node.Value, lookup)
+		for _, err := range errs {
+			v.errorf(node, "%v", err)
+		}
+		if len(errs) == 0 && value != node.Value {
+			node.Value = value
+			if node.Style == 0 {
+				node.Tag = ""
+				node.Tag = node.ShortTag()
+			}
+		}
+	}
+}
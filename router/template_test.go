@@ -0,0 +1,84 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	env := map[string]string{"HOST": "api.example.com", "EMPTY": "", "ENV": " Prod "}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		s    string
+		want string
+		errs []string
+	}{
+		{s: "${HOST}", want: "api.example.com"},
+		{s: "http://${HOST}:${PORT:-8080}/", want: "http://api.example.com:8080/"},
+		{s: "${EMPTY:-default}", want: "default"},
+		{s: "${EMPTY}", want: ""},
+		{s: "${ENV | trim | lower}-users", want: "prod-users"},
+		{s: "^${HOST | quoteMeta}$", want: `^api\.example\.com$`},
+		{s: "${HOST | trimSuffix .com | replace . -}", want: "api-example"},
+		{s: "${MISSING:-a.b | upper}", want: "A.B"},
+		{s: "$${HOST} $HOST", want: "${HOST} $HOST"},
+		{s: "^${RE:-[0-9]{4}}$", want: "^[0-9]{4}$"},
+		{s: `${RE:-a\{2\}}`, want: "a{2}"},
+		{s: `${CLOSE:-a\}b}`, want: "a}b"},
+		{s: `${RE:-(a\|b)}`, want: "(a|b)"},
+		{s: `${HOST | replace . \}}`, want: "api}example}com"},
+		{s: "${HOST", want: "${HOST", errs: []string{"unterminated '${HOST'"}},
+		{s: "${A}/${B}", want: "${A}/${B}", errs: []string{"unresolved variable 'A'", "unresolved variable 'B'"}},
+		{s: "${HOST | title}", want: "${HOST | title}", errs: []string{"unknown helper 'title'"}},
+		{s: "${HOST | replace .}", want: "${HOST | replace .}", errs: []string{"helper 'replace' expects 2 arguments"}},
+		{s: "${1X}", want: "${1X}", errs: []string{"invalid variable name '1X'"}},
+	}
+
+	for _, tt := range tests {
+		got, errs := expandTemplate(tt.s, lookup)
+		assert.Equal(t, tt.want, got, tt.s)
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		if tt.errs == nil {
+			tt.errs = []string{}
+		}
+		assert.Equal(t, tt.errs, msgs, tt.s)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	t.Setenv("ART_HOST", "api.example.com")
+	t.Setenv("ART_PRIORITY", "10")
+
+	rules, err := Load(strings.NewReader(`
+- hostRegexp: ^${ART_HOST | quoteMeta}$
+  paths:
+  - path: /users
+    backend: ${ART_BACKEND:-users}
+    priority: ${ART_PRIORITY}
+    headers:
+    - key: X-Env
+      values: ["${ART_PRIORITY}"]
+`), FormatYAML)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Rule{{
+		HostRegexp: `^api\.example\.com$`,
+		Paths: []*Path{{
+			Path:     "/users",
+			Backend:  "users",
+			Priority: 10,
+			Headers:  []*Header{{Key: "X-Env", Values: []string{"10"}}},
+		}},
+	}}, rules)
+
+	_, err = Load(strings.NewReader(`[{"host": "${ART_MISSING_HOST}", "paths": [{"path": "/a", "backend": "${ART_MISSING_BACKEND}"}]}]`), FormatJSON)
+	assert.EqualError(t, err, "1:11: unresolved variable 'ART_MISSING_HOST'\n1:70: unresolved variable 'ART_MISSING_BACKEND'")
+}
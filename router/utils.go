@@ -6,6 +6,7 @@ import (
 )
 
 type segment struct {
+	check    func(string) bool
 	key      string
 	rexpat   string
 	ps       int
@@ -26,9 +27,15 @@ func patNextSegment(pattern string) segment {
 		}
 	}
 
-	// Sanity check
-	if ps >= 0 && ws >= 0 && ws < ps {
-		panic("wildcard '*' must be the last pattern in a route, otherwise use a '{param}'")
+	// Wildcard followed by a suffix, /assets/*.js, it's a multi-segment param
+	if ws >= 0 && ws < len(pattern)-1 && (ps < 0 || ws < ps) {
+		return segment{
+			nodeType: ntMultiParam,
+			key:      "*",
+			tail:     pattern[ws+1],
+			ps:       ws,
+			pe:       ws + 1,
+		}
 	}
 
 	// Wildcard pattern as finale
@@ -65,35 +72,50 @@ func patNextSegment(pattern string) segment {
 		}
 
 		var rexpat string
-		if idx := strings.Index(key, ":"); idx >= 0 {
+		if strings.HasSuffix(key, "*") && !strings.Contains(key, ":") {
+			// named wildcard, a catch-all at the end of the pattern
+			key = key[:len(key)-1]
+			if pe == len(pattern) {
+				return segment{
+					nodeType: ntCatchAll,
+					key:      key,
+					ps:       ps,
+					pe:       pe,
+				}
+			}
+			nt = ntMultiParam
+		} else if strings.HasSuffix(key, "...") {
+			// multi-segment param
+			nt = ntMultiParam
+			key = key[:len(key)-3]
+		} else if idx := strings.Index(key, ":"); idx >= 0 {
 			nt = ntRegexp
 			rexpat = key[idx+1:]
 			key = key[:idx]
 		}
 
-		if len(rexpat) > 0 {
-			if rexpat[0] != '^' {
-				rexpat = "^" + rexpat
-			}
-			if rexpat[len(rexpat)-1] != '$' {
-				rexpat += "$"
-			}
+		// named param types keep their name as the pattern
+		var check func(string) bool
+		if nt == ntRegexp {
+			check = lookupParamType(rexpat)
+		}
+
+		if len(rexpat) > 0 && check == nil {
+			// anchor the whole pattern, an alternation included
+			rexpat = "^(?:" + trimAnchors(rexpat) + ")$"
 		}
 
 		return segment{
 			nodeType: nt,
 			key:      key,
 			rexpat:   rexpat,
+			check:    check,
 			tail:     tail,
 			ps:       ps,
 			pe:       pe,
 		}
 	}
 
-	if ws < len(pattern)-1 {
-		panic("wildcard '*' must be the last value in a route. trim trailing text or use a '{param}' instead")
-	}
-
 	return segment{
 		nodeType: ntCatchAll,
 		key:      "*",
@@ -102,6 +124,22 @@ func patNextSegment(pattern string) segment {
 	}
 }
 
+// trimAnchors removes a leading ^ and a trailing $ that isn't escaped.
+func trimAnchors(rexpat string) string {
+	rexpat = strings.TrimPrefix(rexpat, "^")
+	if strings.HasSuffix(rexpat, "$") {
+		n := len(rexpat) - 1
+		i := n
+		for i > 0 && rexpat[i-1] == '\\' {
+			i--
+		}
+		if (n-i)%2 == 0 {
+			rexpat = rexpat[:n]
+		}
+	}
+	return rexpat
+}
+
 func patParamKeys(pattern string) []string {
 	pat := pattern
 	paramKeys := []string{}
@@ -120,6 +158,137 @@ func patParamKeys(pattern string) []string {
 	}
 }
 
+// expandPattern expands the optional parts of a pattern into all the patterns
+// it matches, the first one contains every part. A part enclosed in [ ] is
+// optional, e.g. /users[/{id}], and so is a param with a trailing ? in its key,
+// /users/{id?} is the same as /users[/{id}].
+func expandPattern(pattern string) []string {
+	pattern = rewriteOptionalParams(pattern)
+
+	if strings.IndexByte(pattern, '[') < 0 && strings.IndexByte(pattern, ']') < 0 {
+		return []string{pattern}
+	}
+
+	patterns := []string{}
+	for _, p := range expandBrackets(pattern) {
+		if p == "" {
+			p = "/"
+		}
+		if !StrInSlice(p, patterns) {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// rewriteOptionalParams rewrites {id?} into [{id}], the separator before the
+// param is made optional as well if the param spans a whole segment.
+func rewriteOptionalParams(pattern string) string {
+	if !strings.Contains(pattern, "?") {
+		return pattern
+	}
+
+	var sb strings.Builder
+	i := 0
+	for i < len(pattern) {
+		if pattern[i] != '{' {
+			sb.WriteByte(pattern[i])
+			i++
+			continue
+		}
+
+		end := closingBrace(pattern, i)
+		if end < 0 {
+			panic("route param closing delimiter '}' is missing")
+		}
+
+		param := pattern[i : end+1]
+		key := param[1 : len(param)-1]
+		if idx := strings.IndexByte(key, ':'); idx >= 0 {
+			key = key[:idx]
+		}
+
+		if !strings.HasSuffix(key, "?") {
+			sb.WriteString(param)
+			i = end + 1
+			continue
+		}
+
+		param = param[:len(key)] + param[len(key)+1:]
+
+		str := sb.String()
+		if strings.HasSuffix(str, "/") && (end+1 == len(pattern) || pattern[end+1] == '/') {
+			sb.Reset()
+			sb.WriteString(str[:len(str)-1])
+			param = "/" + param
+		}
+
+		sb.WriteByte('[')
+		sb.WriteString(param)
+		sb.WriteByte(']')
+		i = end + 1
+	}
+	return sb.String()
+}
+
+// expandBrackets expands the first top level [ ] group recursively.
+func expandBrackets(pattern string) []string {
+	start, end := -1, -1
+	depth := 0
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			e := closingBrace(pattern, i)
+			if e < 0 {
+				panic("route param closing delimiter '}' is missing")
+			}
+			i = e
+		case '[':
+			if depth == 0 && start < 0 {
+				start = i
+			}
+			depth++
+		case ']':
+			depth--
+			if depth < 0 {
+				panic(fmt.Sprintf("routing pattern '%s' contains unbalanced ']'", pattern))
+			}
+			if depth == 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+
+	if depth != 0 {
+		panic(fmt.Sprintf("routing pattern '%s' contains unbalanced '['", pattern))
+	}
+
+	if start < 0 {
+		return []string{pattern}
+	}
+
+	with := expandBrackets(pattern[:start] + pattern[start+1:end] + pattern[end+1:])
+	without := expandBrackets(pattern[:start] + pattern[end+1:])
+	return append(with, without...)
+}
+
+// closingBrace returns the index of the } closing the { at start, or -1.
+func closingBrace(pattern string, start int) int {
+	cc := 0
+	for i := start; i < len(pattern); i++ {
+		if pattern[i] == '{' {
+			cc++
+		} else if pattern[i] == '}' {
+			cc--
+			if cc == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 // longestPrefix finds the length of the shared prefix
 // of two strings
 func longestPrefix(k1, k2 string) int {
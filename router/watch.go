@@ -0,0 +1,182 @@
+package router
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// WatchOptions configures a Watcher.
+	WatchOptions struct {
+		// Interval is the polling interval, 1s by default
+		Interval time.Duration
+		// Debounce is how long the files must stay unchanged before a reload,
+		// so that a reload doesn't see half written files, 500ms by default
+		// and none if negative
+		Debounce time.Duration
+		// DisablePathCache is passed to New
+		DisablePathCache bool
+		// OnReload is called after each reload with the error of a failed one
+		OnReload func(err error)
+	}
+
+	// Watcher keeps a router in sync with config files, see LoadFile. It polls
+	// the files and the files they include instead of relying on notifications
+	// of the OS, a file counts as changed when its content hash changes. Config
+	// files are small, they are hashed at every poll as the modification time
+	// misses the writes within its granularity.
+	Watcher struct {
+		router atomic.Value // *ArtRouter
+		files  []string
+		// included lists the files read by the last build
+		included []string
+		opts     WatchOptions
+		hashes   map[string][sha256.Size]byte
+		stop     chan struct{}
+		done     chan struct{}
+		mu       sync.Mutex // serializes the reloads, guards hashes and included
+		once     sync.Once
+	}
+)
+
+// NewWatcher loads the rules of the files, in this order and composed like the
+// includes of a Config, and starts polling them, it returns an error if the
+// initial load fails.
+func NewWatcher(opts WatchOptions, files ...string) (*Watcher, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files to watch")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Debounce < 0 {
+		opts.Debounce = 0
+	} else if opts.Debounce == 0 {
+		opts.Debounce = 500 * time.Millisecond
+	}
+
+	w := &Watcher{
+		files:  files,
+		opts:   opts,
+		hashes: map[string][sha256.Size]byte{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	ar, err := w.build()
+	if err != nil {
+		return nil, err
+	}
+	w.router.Store(ar)
+
+	go w.run()
+
+	return w, nil
+}
+
+// Router returns the current router, it's replaced as a whole on reload.
+func (w *Watcher) Router() *ArtRouter {
+	return w.router.Load().(*ArtRouter)
+}
+
+// Search searches the current router.
+func (w *Watcher) Search(req *http.Request) *Context {
+	return w.Router().Search(req)
+}
+
+// Reload rebuilds the router from the files now, the current router is kept if
+// the files fail to load or build. OnReload is called once the reload is done,
+// it may call Reload itself.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	ar, err := w.build()
+	if err == nil {
+		w.router.Store(ar)
+	}
+	w.mu.Unlock()
+
+	if w.opts.OnReload != nil {
+		w.opts.OnReload(err)
+	}
+	return err
+}
+
+// Close stops polling the files.
+func (w *Watcher) Close() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	pending := false
+	var changedAt time.Time
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			if w.changed() {
+				pending, changedAt = true, now
+			}
+			if pending && now.Sub(changedAt) >= w.opts.Debounce {
+				pending = false
+				_ = w.Reload()
+			}
+		}
+	}
+}
+
+// changed hashes the files and reports whether a hash changed, a missing file
+// has the hash of an empty content.
+func (w *Watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	changed := false
+	for _, f := range append(append([]string(nil), w.files...), w.included...) {
+		data, _ := os.ReadFile(f)
+		h := sha256.Sum256(data)
+		if old, ok := w.hashes[f]; !ok || old != h {
+			w.hashes[f] = h
+			changed = true
+		}
+	}
+	return changed
+}
+
+// build loads the rules of the files and builds a router. The files read and
+// the hashes of their contents are kept even if the build fails, a later
+// change of the files is seen by changed.
+func (w *Watcher) build() (*ArtRouter, error) {
+	c := newConfigComposer()
+	defer func() {
+		w.included = c.files
+		for f, sum := range c.sums {
+			w.hashes[f] = sum
+		}
+	}()
+
+	for _, f := range w.files {
+		if err := c.addFile(f, true); err != nil {
+			return nil, err
+		}
+	}
+	rules, err := c.compose("")
+	if err != nil {
+		return nil, err
+	}
+	return buildRouter(rules, w.opts.DisablePathCache)
+}
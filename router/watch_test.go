@@ -0,0 +1,148 @@
+package router
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts.yaml")
+	paths := filepath.Join(dir, "paths.json")
+	assert.Nil(t, os.WriteFile(hosts, []byte("- host: a.com\n  paths:\n  - {path: /a, backend: a1}\n"), 0o644))
+	assert.Nil(t, os.WriteFile(paths, []byte(`[{"paths": [{"path": "/b", "backend": "b1"}]}]`), 0o644))
+
+	reloads := make(chan error, 10)
+	w, err := NewWatcher(WatchOptions{
+		Interval: 5 * time.Millisecond,
+		Debounce: 20 * time.Millisecond,
+		OnReload: func(err error) { reloads <- err },
+	}, hosts, paths)
+	assert.Nil(t, err)
+	defer w.Close()
+
+	backend := func(url string) string {
+		req, _ := http.NewRequest("GET", url, nil)
+		if context := w.Search(req); context.Route != nil {
+			return context.Route.backend
+		}
+		return ""
+	}
+	wait := func() error {
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(2 * time.Second):
+			t.Fatal("no reload")
+		}
+		return nil
+	}
+
+	assert.Equal(t, "a1", backend("http://a.com/a"))
+	assert.Equal(t, "b1", backend("http://b.com/b"))
+
+	// several writes in a row are applied at once
+	assert.Nil(t, os.WriteFile(paths, []byte(`[{"paths": [{"path": "/b", "backend": "b2"}]}]`), 0o644))
+	assert.Nil(t, os.WriteFile(paths, []byte(`[{"paths": [{"path": "/b", "backend": "b3"}]}]`), 0o644))
+	assert.Nil(t, wait())
+	assert.Equal(t, "b3", backend("http://b.com/b"))
+
+	// an invalid file keeps the previous router
+	assert.Nil(t, os.WriteFile(hosts, []byte("- host: a.com\n  paths:\n  - {path: a, backend: a2}\n"), 0o644))
+	assert.NotNil(t, wait())
+	assert.Equal(t, "a1", backend("http://a.com/a"))
+	assert.Equal(t, "b3", backend("http://b.com/b"))
+
+	assert.Nil(t, os.WriteFile(hosts, []byte("- host: a.com\n  paths:\n  - {path: /a, backend: a2}\n"), 0o644))
+	assert.Nil(t, wait())
+	assert.Equal(t, "a2", backend("http://a.com/a"))
+
+	// a missing file fails the reload
+	assert.Nil(t, os.Remove(paths))
+	assert.NotNil(t, wait())
+	assert.Equal(t, "b3", backend("http://b.com/b"))
+
+	select {
+	case err := <-reloads:
+		t.Fatalf("unexpected reload: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatcherIncludes(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root.yaml")
+	team := filepath.Join(dir, "team.yaml")
+	assert.Nil(t, os.WriteFile(root, []byte("include: [team.yaml]\n"), 0o644))
+	assert.Nil(t, os.WriteFile(team, []byte("- paths: [{path: /a, backend: a1}]\n"), 0o644))
+
+	reloads := make(chan error, 10)
+	w, err := NewWatcher(WatchOptions{
+		Interval: 5 * time.Millisecond,
+		Debounce: -1,
+		OnReload: func(err error) { reloads <- err },
+	}, root)
+	assert.Nil(t, err)
+	defer w.Close()
+
+	// a change of an included file reloads the router
+	assert.Nil(t, os.WriteFile(team, []byte("- paths: [{path: /a, backend: a2}]\n"), 0o644))
+	select {
+	case err := <-reloads:
+		assert.Nil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reload")
+	}
+	req, _ := http.NewRequest("GET", "http://a.com/a", nil)
+	assert.Equal(t, "a2", w.Search(req).Route.backend)
+}
+
+func TestWatcherErrors(t *testing.T) {
+	_, err := NewWatcher(WatchOptions{})
+	assert.NotNil(t, err)
+
+	dir := t.TempDir()
+	_, err = NewWatcher(WatchOptions{}, filepath.Join(dir, "missing.yaml"))
+	assert.NotNil(t, err)
+
+	filename := filepath.Join(dir, "rules.yaml")
+	assert.Nil(t, os.WriteFile(filename, []byte("- paths:\n  - {path: /a, backend: a}\n"), 0o644))
+	w, err := NewWatcher(WatchOptions{}, filename)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Reload())
+	w.Close()
+	w.Close()
+}
+
+func TestWatcherReloadFromCallback(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.yaml")
+	assert.Nil(t, os.WriteFile(filename, []byte("- paths:\n  - {path: /a, backend: a}\n"), 0o644))
+
+	var w *Watcher
+	calls := 0
+	opts := WatchOptions{Interval: time.Hour, OnReload: func(err error) {
+		calls++
+		if calls == 1 {
+			w.changed()
+			assert.Nil(t, w.Reload())
+		}
+	}}
+	w, err := NewWatcher(opts, filename)
+	assert.Nil(t, err)
+	defer w.Close()
+
+	done := make(chan error)
+	go func() { done <- w.Reload() }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("deadlock")
+	}
+	assert.Equal(t, 2, calls)
+}